@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+const (
+	msgAnalyzeArgsMissing = "(⁎˃ᆺ˂) file to analyze is missing\n"
+	msgAnalyzeFailed      = "(⁎˃ᆺ˂) %s can not be analyzed: %s\n"
+	msgAnalyzeFormats     = "(⁎˃ᆺ˂) -json and -csv can not be used together\n"
+	msgAnalyzeSummary     = "( ^..^)ﾉ  %s: %d bytes\n" +
+		"entropy is %.4f bits per byte, theoretical minimum is %.0f bytes\n" +
+		"huffman average code length is %.4f bits, codes take %d bytes\n" +
+		"serialized tree takes %d bytes, huffman output is %d bytes\n"
+	analyzeCommand = "analyze"
+	analyzeHeader  = "symbol\tfrequency\tideal length\tcode length\t"
+)
+
+func runAnalyze(args []string) {
+	fset := flag.NewFlagSet(analyzeCommand, flag.ExitOnError)
+	asJSON := fset.Bool("json", false, "print analysis as JSON")
+	asCSV := fset.Bool("csv", false, "print symbol table as CSV")
+	_ = fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		fmt.Print(msgAnalyzeArgsMissing)
+		os.Exit(-1)
+	}
+	if *asJSON && *asCSV {
+		fmt.Print(msgAnalyzeFormats)
+		os.Exit(-1)
+	}
+	path := fset.Arg(0)
+	inf, err := os.Open(path)
+	if err != nil {
+		fmt.Printf(msgSrcFileNotOpen, filepath.Base(path), err)
+		os.Exit(-1)
+	}
+	defer inf.Close()
+	a, err := internal.Analyze(inf)
+	if err != nil {
+		fmt.Printf(msgAnalyzeFailed, filepath.Base(path), err)
+		os.Exit(-1)
+	}
+
+	switch {
+	case *asJSON:
+		out, _ := json.MarshalIndent(a, "", "  ")
+		fmt.Println(string(out))
+	case *asCSV:
+		cw := csv.NewWriter(os.Stdout)
+		_ = cw.Write([]string{"symbol", "frequency", "ideal_length", "code_length"})
+		for _, s := range a.Symbols {
+			_ = cw.Write([]string{
+				strconv.Itoa(int(s.Symbol)),
+				strconv.FormatUint(s.Frequency, 10),
+				strconv.FormatFloat(s.IdealLength, 'f', 4, 64),
+				strconv.Itoa(s.CodeLength),
+			})
+		}
+		cw.Flush()
+	default:
+		fmt.Printf(msgAnalyzeSummary, path, a.Size, a.Entropy, a.MinSize,
+			a.AverageCode, a.CodesSize, a.TreeSize, a.CompressedSize)
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, analyzeHeader)
+		for _, s := range a.Symbols {
+			fmt.Fprintf(tw, "%s\t%d\t%.4f\t%d\t\n", symbolName(s.Symbol), s.Frequency, s.IdealLength, s.CodeLength)
+		}
+		tw.Flush()
+	}
+}
+
+// symbolName quotes printable bytes and shows others in hex.
+func symbolName(b byte) string {
+	if b >= 0x20 && b < 0x7f {
+		return strconv.QuoteRune(rune(b))
+	}
+	return fmt.Sprintf("0x%02x", b)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+const msgInterrupted = "(⁎˃ᆺ˂) interrupted by %s, partial output is removed\n"
+
+// outputFile is written to temporary file in destination directory, which
+// replaces destination only on commit. Appended file is written in place and
+// truncated back on abort.
+type outputFile struct {
+	*os.File
+	path      string
+	appending bool
+	prevSize  int64
+}
+
+// pendingOutputs are aborted when process is interrupted.
+var pendingOutputs = struct {
+	sync.Mutex
+	files map[*outputFile]struct{}
+}{files: map[*outputFile]struct{}{}}
+
+// handleSignals aborts pending outputs on interrupt.
+func handleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		pendingOutputs.Lock()
+		for of := range pendingOutputs.files {
+			of.cleanup()
+		}
+		fmt.Printf(msgInterrupted, sig)
+		os.Exit(-1)
+	}()
+}
+
+// createOutput creates output file with given permissions or opens it for
+// appending. Existing file is overwritten only with -f flag.
+func createOutput(path string, appending bool, perm fs.FileMode) (*outputFile, error) {
+	of := &outputFile{path: path, appending: appending}
+	var err error
+	if appending {
+		if of.File, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm); err != nil {
+			return nil, err
+		}
+		stat, err := of.Stat()
+		if err != nil {
+			of.Close()
+			return nil, err
+		}
+		of.prevSize = stat.Size()
+	} else {
+		if err := checkOverwrite(path); err != nil {
+			return nil, err
+		}
+		dir, name := filepath.Split(path)
+		if of.File, err = os.CreateTemp(dir, "."+name+".*.tmp"); err != nil {
+			return nil, err
+		}
+		if err := of.Chmod(perm); err != nil {
+			of.cleanup()
+			return nil, err
+		}
+	}
+	pendingOutputs.Lock()
+	pendingOutputs.files[of] = struct{}{}
+	pendingOutputs.Unlock()
+	return of, nil
+}
+
+func checkOverwrite(path string) error {
+	if _, err := os.Lstat(path); err == nil && !*force {
+		return &fs.PathError{Op: "create", Path: path, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// commit syncs output and moves it to destination.
+func (of *outputFile) commit() error {
+	defer of.forget()
+	if err := of.Sync(); err != nil {
+		of.cleanup()
+		return err
+	}
+	if err := of.Close(); err != nil {
+		of.cleanup()
+		return err
+	}
+	if of.appending {
+		return nil
+	}
+	if err := checkOverwrite(of.path); err != nil {
+		_ = os.Remove(of.Name())
+		return err
+	}
+	if err := os.Rename(of.Name(), of.path); err != nil {
+		_ = os.Remove(of.Name())
+		return err
+	}
+	// persist rename
+	if dir, err := os.Open(filepath.Dir(of.path)); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// abort drops output keeping destination intact.
+func (of *outputFile) abort() {
+	of.cleanup()
+	of.forget()
+}
+
+func (of *outputFile) cleanup() {
+	if of.appending {
+		_ = of.Truncate(of.prevSize)
+		of.Close()
+	} else {
+		of.Close()
+		_ = os.Remove(of.Name())
+	}
+}
+
+func (of *outputFile) forget() {
+	pendingOutputs.Lock()
+	delete(pendingOutputs.files, of)
+	pendingOutputs.Unlock()
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	msgBenchArgsMissing = "(⁎˃ᆺ˂) files to benchmark are missing\n"
+	msgBenchFailed      = "(⁎˃ᆺ˂) %s with %s can not be benchmarked: %s\n"
+	msgBenchBadLevels   = "(⁎˃ᆺ˂) bad levels '%s', expected comma separated numbers from %d to %d\n"
+	msgBenchMismatch    = "decompressed data differs from input"
+	benchCommand        = "bench"
+	benchHeader         = "file\talgorithm\tlevel\tratio\tcompress MB/s\tdecompress MB/s\t" +
+		"compress allocs\tcompress alloc MB\tdecompress allocs\tdecompress alloc MB\t"
+)
+
+// benchResult holds averages of benchmark iterations.
+type benchResult struct {
+	File             string  `json:"file"`
+	Algorithm        string  `json:"algorithm"`
+	Level            int     `json:"level,omitempty"`
+	Size             int     `json:"input_size"`
+	CompressedSize   int     `json:"output_size"`
+	Ratio            float64 `json:"ratio"`
+	CompressSpeed    float64 `json:"compress_mb_per_second"`
+	DecompressSpeed  float64 `json:"decompress_mb_per_second"`
+	CompressAllocs   uint64  `json:"compress_allocs"`
+	CompressBytes    uint64  `json:"compress_alloc_bytes"`
+	DecompressAllocs uint64  `json:"decompress_allocs"`
+	DecompressBytes  uint64  `json:"decompress_alloc_bytes"`
+}
+
+type benchConfig struct {
+	algorithm string
+	level     int
+}
+
+func runBench(args []string) {
+	fset := flag.NewFlagSet(benchCommand, flag.ExitOnError)
+	iterations := fset.Int("n", 3, "number of iterations")
+	algos := fset.String("algo", "", "comma separated algorithms, all by default: "+internal.AlgorithmNames())
+	levelList := fset.String("levels", "", fmt.Sprintf("comma separated levels of %s algorithm, all by default", internal.DefaultAlgorithm))
+	asJSON := fset.Bool("json", false, "print JSON object per result instead of table")
+	_ = fset.Parse(args)
+
+	if fset.NArg() == 0 {
+		fmt.Print(msgBenchArgsMissing)
+		os.Exit(-1)
+	}
+	configs := benchConfigs(*algos, *levelList)
+
+	var tw *tabwriter.Writer
+	if !*asJSON {
+		tw = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, benchHeader)
+	}
+	failed := false
+	for _, path := range fset.Args() {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf(msgSrcFileNotOpen, filepath.Base(path), err)
+			os.Exit(-1)
+		}
+		for _, cfg := range configs {
+			res, err := benchmark(input, cfg, max(*iterations, 1))
+			if err != nil {
+				fmt.Printf(msgBenchFailed, filepath.Base(path), cfg.algorithm, err)
+				failed = true
+				continue
+			}
+			res.File = path
+			if *asJSON {
+				out, err := json.Marshal(res)
+				if err != nil {
+					fmt.Printf(msgBenchFailed, filepath.Base(path), cfg.algorithm, err)
+					failed = true
+					continue
+				}
+				fmt.Println(string(out))
+				continue
+			}
+			level := "-"
+			if res.Level != 0 {
+				level = strconv.Itoa(res.Level)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.3f\t%.2f\t%.2f\t%d\t%.2f\t%d\t%.2f\t\n",
+				res.File, res.Algorithm, level, res.Ratio, res.CompressSpeed, res.DecompressSpeed,
+				res.CompressAllocs, float64(res.CompressBytes)/1e6,
+				res.DecompressAllocs, float64(res.DecompressBytes)/1e6)
+		}
+	}
+	if tw != nil {
+		tw.Flush()
+	}
+	if failed {
+		os.Exit(-1)
+	}
+}
+
+// benchConfigs lists selected algorithms, block format once per level.
+func benchConfigs(algos, levelList string) []benchConfig {
+	names := strings.Split(internal.AlgorithmNames(), ",")
+	if algos != "" {
+		names = strings.Split(algos, ",")
+	}
+	var levels []int
+	if levelList == "" {
+		for level := internal.MinLevel; level <= internal.MaxLevel; level++ {
+			levels = append(levels, level)
+		}
+	} else {
+		for _, s := range strings.Split(levelList, ",") {
+			level, err := strconv.Atoi(s)
+			if err != nil || level < internal.MinLevel || level > internal.MaxLevel {
+				fmt.Printf(msgBenchBadLevels, levelList, internal.MinLevel, internal.MaxLevel)
+				os.Exit(-1)
+			}
+			levels = append(levels, level)
+		}
+	}
+
+	var configs []benchConfig
+	for _, name := range names {
+		if _, err := internal.NewEncoderDecoder(name, internal.Options{}); err != nil {
+			fmt.Printf(msgBadAlgorithm, err, internal.AlgorithmNames())
+			os.Exit(-1)
+		}
+		if name != internal.DefaultAlgorithm {
+			configs = append(configs, benchConfig{algorithm: name})
+			continue
+		}
+		for _, level := range levels {
+			configs = append(configs, benchConfig{algorithm: name, level: level})
+		}
+	}
+	return configs
+}
+
+func benchmark(input []byte, cfg benchConfig, iterations int) (*benchResult, error) {
+	enc, err := internal.NewEncoderDecoder(cfg.algorithm, internal.Options{Level: cfg.level})
+	if err != nil {
+		return nil, err
+	}
+	res := &benchResult{Algorithm: cfg.algorithm, Level: cfg.level, Size: len(input)}
+
+	var encoded bytes.Buffer
+	duration, allocs, allocBytes, err := measure(iterations, func() error {
+		encoded.Reset()
+		return enc.Encode(bytes.NewReader(input), &encoded)
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.CompressedSize = encoded.Len()
+	if encoded.Len() > 0 {
+		res.Ratio = float64(len(input)) / float64(encoded.Len())
+	}
+	res.CompressSpeed = throughput(len(input), duration)
+	res.CompressAllocs, res.CompressBytes = allocs, allocBytes
+
+	var decoded bytes.Buffer
+	duration, allocs, allocBytes, err = measure(iterations, func() error {
+		decoded.Reset()
+		return enc.Decode(bytes.NewReader(encoded.Bytes()), &decoded)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(decoded.Bytes(), input) {
+		return nil, errors.New(msgBenchMismatch)
+	}
+	res.DecompressSpeed = throughput(len(input), duration)
+	res.DecompressAllocs, res.DecompressBytes = allocs, allocBytes
+	return res, nil
+}
+
+// measure runs f iterations times and returns average duration, # of
+// allocations and allocated bytes.
+func measure(iterations int, f func() error) (time.Duration, uint64, uint64, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		if err := f(); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+	n := uint64(iterations)
+	return duration / time.Duration(iterations), (after.Mallocs - before.Mallocs) / n,
+		(after.TotalAlloc - before.TotalAlloc) / n, nil
+}
+
+func throughput(size int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(size) / 1e6 / d.Seconds()
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"crypto/sha256"
+	"errors"
 	"flag"
 	"fmt"
 	"go-compressor/internal"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"runtime/pprof"
 	"time"
 )
 
 const (
-	msgArgsMissing          = "(⁎˃ᆺ˂) source and (or) destination files are missing\n"
+	msgArgsMissing          = "(⁎˃ᆺ˂) source file is missing\n"
+	msgBadSourceName        = "(⁎˃ᆺ˂) source file '%s' is skipped: %s\n"
+	msgDstFileExists        = "(⁎˃ᆺ˂) output file '%s' already exists, use -f to overwrite it\n"
+	msgSrcFileNotRemoved    = "(ᵕ—ᴗ—) source file '%s' can not be removed: %s\n"
 	msgSrcFileNotOpen       = "(⁎˃ᆺ˂) source file '%s' can not be open: %s\n"
 	msgDstFileNotCreated    = "(⁎˃ᆺ˂) output file '%s' can not be created: %s\n"
 	msgCompressionFailed    = "(⁎˃ᆺ˂) can not compress: %s\n"
@@ -20,14 +28,37 @@ const (
 	msgDecompressionSuccess = "(=^ ◡ ^=) successfully decompressed to file '%s'\n"
 	msgCompressionRate      = "( ^..^)ﾉ  compression rate is %.2f\n"
 	msgRuntime              = "(^･o･^)ﾉ  gocmp running time is %s\n"
+	msgAppendUnsupported    = "(⁎˃ᆺ˂) only %s files can be appended to\n"
+	msgMetadataNotRead      = "(⁎˃ᆺ˂) metadata can not be read: %s\n"
+	msgMetadataNotRestored  = "(ᵕ—ᴗ—) metadata of file '%s' can not be restored: %s\n"
+	msgProfileNotWritten    = "(ᵕ—ᴗ—) memory profile '%s' can not be written: %s\n"
 )
 
 var (
 	cpuprofile     = flag.String("cpuprofile", "", "write cpu profile to this file")
+	memprofile     = flag.String("memprofile", "", "write memory profile to this file")
 	decompressMode = flag.Bool("d", false, "enable decompression mode")
+	appendMode     = flag.Bool("a", false, "append compressed stream to existing output file")
+	singleMember   = flag.Bool("single", false, "decompress only the first of concatenated streams")
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == trainCommand {
+		runTrain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == recoverCommand {
+		runRecover(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == benchCommand {
+		runBench(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == analyzeCommand {
+		runAnalyze(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
 	// debugging features
@@ -39,52 +70,212 @@ func main() {
 	}
 
 	args := flag.Args()
-	if len(args) != 2 {
+	if len(args) != 1 && len(args) != 2 {
 		fmt.Print(msgArgsMissing)
 		os.Exit(-1)
 	}
+	if *appendMode && !*decompressMode && *algorithm != internal.DefaultAlgorithm {
+		fmt.Printf(msgAppendUnsupported, internal.DefaultAlgorithm)
+		os.Exit(-1)
+	}
+	opts := encoderOptions()
+	checkAlgorithm(opts)
+	handleSignals()
 
-	srcPath := args[0]
-	dstPath := args[1]
+	startTime := time.Now()
+	if *recursive {
+		if !runRecursive(args, opts) {
+			os.Exit(-1)
+		}
+	} else {
+		runSingle(args, opts)
+	}
+	finishTime := time.Now()
+	say(msgRuntime, finishTime.Sub(startTime))
 
-	srcName := filepath.Base(srcPath)
-	dstName := filepath.Base(dstPath)
+	if *memprofile != "" {
+		if err := writeMemProfile(*memprofile); err != nil {
+			fmt.Fprintf(os.Stderr, msgProfileNotWritten, *memprofile, err)
+		}
+	}
+}
 
-	inf, err := os.Open(srcPath)
+func writeMemProfile(path string) error {
+	memf, err := os.Create(path)
 	if err != nil {
-		fmt.Printf(msgSrcFileNotOpen, srcName, err)
-		os.Exit(-1)
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(memf); err != nil {
+		memf.Close()
+		return err
+	}
+	return memf.Close()
+}
+
+func runSingle(args []string, opts internal.Options) {
+	srcPath := args[0]
+	// with omitted destination source is replaced like in gzip
+	inPlace := len(args) == 1
+	var dstPath string
+	if inPlace {
+		var err error
+		if dstPath, err = defaultOutputPath(srcPath, *decompressMode); err != nil {
+			fmt.Printf(msgBadSourceName, filepath.Base(srcPath), err)
+			os.Exit(-1)
+		}
+	} else {
+		dstPath = args[1]
 	}
 
-	outf, err := os.Create(dstPath)
+	if progressShown() {
+		if stat, err := os.Stat(srcPath); err == nil {
+			opts.Progress = progressBar(stat.Size())
+		}
+	}
+	res, err := processFile(srcPath, dstPath, inPlace, opts)
+	if opts.Progress != nil {
+		clearProgress()
+	}
+	if *jsonOutput {
+		if err := printJSON(srcPath, res, err); err != nil {
+			os.Exit(-1)
+		}
+	} else if err != nil {
+		fmt.Print(err)
+	}
 	if err != nil {
-		fmt.Printf(msgDstFileNotCreated, dstName, err)
 		os.Exit(-1)
 	}
+	if *decompressMode {
+		say(msgDecompressionSuccess, filepath.Base(res.dstPath))
+	} else {
+		say(msgCompressionSuccess, filepath.Base(res.dstPath))
+		say(msgCompressionRate, res.rate())
+	}
+}
 
-	enc := internal.NewHuffmanEncoderDecoder()
+// failure is error of file processing, it is printed with message format.
+type failure struct {
+	msg  string
+	args []any
+}
 
-	startTime := time.Now()
+func fail(msg string, args ...any) error {
+	return &failure{msg: msg, args: args}
+}
+
+func (f *failure) Error() string {
+	return fmt.Sprintf(f.msg, f.args...)
+}
+
+type fileResult struct {
+	dstPath         string
+	inSize, outSize int64
+	duration        time.Duration
+	// checksum is SHA-256 of output
+	checksum []byte
+}
+
+// sizes returns original and compressed sizes.
+func (res *fileResult) sizes() (float64, float64) {
 	if *decompressMode {
-		if err = enc.Decode(inf, outf); err != nil {
-			fmt.Printf(msgDecompressionFailed, err)
-			_ = os.Remove(dstPath)
-			os.Exit(-1)
+		return float64(res.outSize), float64(res.inSize)
+	}
+	return float64(res.inSize), float64(res.outSize)
+}
+
+// rate returns ratio of original and compressed sizes, or zero for empty
+// output.
+func (res *fileResult) rate() float64 {
+	original, compressed := res.sizes()
+	if compressed == 0 {
+		return 0
+	}
+	return original / compressed
+}
+
+// bitsPerByte returns compressed bits per original byte, or zero for empty
+// original.
+func (res *fileResult) bitsPerByte() float64 {
+	original, compressed := res.sizes()
+	if original == 0 {
+		return 0
+	}
+	return 8 * compressed / original
+}
+
+// processFile compresses or decompresses srcPath to dstPath, removing source
+// in place mode unless -k flag is given.
+func processFile(srcPath, dstPath string, inPlace bool, opts internal.Options) (*fileResult, error) {
+	srcName := filepath.Base(srcPath)
+	inf, err := os.Open(srcPath)
+	if err != nil {
+		return nil, fail(msgSrcFileNotOpen, srcName, err)
+	}
+	defer inf.Close()
+
+	var metadata *internal.FileMetadata
+	if *decompressMode {
+		if metadata, err = internal.ReadMetadata(inf); err == nil {
+			_, err = inf.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			return nil, fail(msgMetadataNotRead, err)
 		}
-		fmt.Printf(msgDecompressionSuccess, dstName)
+		dstPath = restoredPath(dstPath, metadata)
 	} else {
-		if err = enc.Encode(inf, outf); err != nil {
-			fmt.Printf(msgCompressionFailed, err)
-			_ = os.Remove(dstPath)
-			os.Exit(-1)
+		metadata = sourceMetadata(inf)
+	}
+	enc := newEncoderDecoder(opts, metadata)
+	dstName := filepath.Base(dstPath)
+
+	infStat, err := inf.Stat()
+	if err != nil {
+		return nil, fail(msgSrcFileNotOpen, srcName, err)
+	}
+	outf, err := createOutput(dstPath, *appendMode && !*decompressMode, infStat.Mode().Perm())
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fail(msgDstFileExists, dstName)
+	} else if err != nil {
+		return nil, fail(msgDstFileNotCreated, dstName, err)
+	}
+
+	startTime := time.Now()
+	checksum := sha256.New()
+	w := io.MultiWriter(outf, checksum)
+	if *decompressMode {
+		if err = enc.Decode(inf, w); err != nil {
+			outf.abort()
+			return nil, fail(msgDecompressionFailed, err)
 		}
-		fmt.Printf(msgCompressionSuccess, dstName)
-		infStat, infErr := inf.Stat()
-		outfStat, outfErr := outf.Stat()
-		if infErr == nil && outfErr == nil {
-			fmt.Printf(msgCompressionRate, float64(infStat.Size())/float64(outfStat.Size()))
+		if err := restoreMetadata(outf.Name(), metadata); err != nil {
+			warn(msgMetadataNotRestored, dstName, err)
 		}
+	} else if err = enc.Encode(inf, w); err != nil {
+		outf.abort()
+		return nil, fail(msgCompressionFailed, err)
 	}
-	finishTime := time.Now()
-	fmt.Printf(msgRuntime, finishTime.Sub(startTime))
+
+	res := &fileResult{
+		dstPath:  dstPath,
+		inSize:   infStat.Size(),
+		duration: time.Since(startTime),
+		checksum: checksum.Sum(nil),
+	}
+	if outfStat, err := outf.Stat(); err == nil {
+		res.outSize = outfStat.Size() - outf.prevSize
+	}
+	if err := outf.commit(); errors.Is(err, fs.ErrExist) {
+		return nil, fail(msgDstFileExists, dstName)
+	} else if err != nil {
+		return nil, fail(msgDstFileNotCreated, dstName, err)
+	}
+	if inPlace && !*keepSource {
+		inf.Close()
+		if err := os.Remove(srcPath); err != nil {
+			warn(msgSrcFileNotRemoved, srcName, err)
+		}
+	}
+	return res, nil
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"go-compressor/internal"
+	"os"
+	"path/filepath"
+)
+
+var (
+	useName    = flag.Bool("N", false, "decompress to file with stored name, in directory of destination or in destination directory")
+	ignoreName = flag.Bool("n", false, "do not store original name, mode and modification time, or ignore them on decompression")
+)
+
+// sourceMetadata returns metadata stored on compression of src, or nil with
+// -n flag.
+func sourceMetadata(src *os.File) *internal.FileMetadata {
+	stat, err := src.Stat()
+	if err != nil || *ignoreName {
+		return nil
+	}
+	return &internal.FileMetadata{Name: stat.Name(), Mode: stat.Mode().Perm(), ModTime: stat.ModTime()}
+}
+
+// restoredPath returns path of decompressed file with stored name.
+func restoredPath(dstPath string, m *internal.FileMetadata) string {
+	if !*useName || *ignoreName || m == nil || !validName(m.Name) {
+		return dstPath
+	}
+	if stat, err := os.Stat(dstPath); err == nil && stat.IsDir() {
+		return filepath.Join(dstPath, m.Name)
+	}
+	return filepath.Join(filepath.Dir(dstPath), m.Name)
+}
+
+// validName rejects stored names that would escape destination directory.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name && filepath.IsLocal(name)
+}
+
+// restoreMetadata sets mode and modification time of decompressed file.
+func restoreMetadata(path string, m *internal.FileMetadata) error {
+	if m == nil || *ignoreName {
+		return nil
+	}
+	if m.Mode != 0 {
+		if err := os.Chmod(path, m.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+	if !m.ModTime.IsZero() {
+		return os.Chtimes(path, m.ModTime, m.ModTime)
+	}
+	return nil
+}
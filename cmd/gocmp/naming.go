@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// compressedSuffix is added to compressed files when destination is omitted.
+const compressedSuffix = ".gcmp"
+
+var (
+	keepSource = flag.Bool("k", false, "keep source file when destination is omitted")
+	force      = flag.Bool("f", false, "overwrite existing output file")
+)
+
+var errSuffix = errors.New("unexpected suffix")
+
+// defaultOutputPath returns destination for single source argument, that is
+// file with added or removed suffix. Compressed file is not compressed again
+// without explicit destination.
+func defaultOutputPath(srcPath string, decompress bool) (string, error) {
+	hasSuffix := strings.HasSuffix(srcPath, compressedSuffix) && len(srcPath) > len(compressedSuffix)
+	if decompress {
+		if !hasSuffix {
+			return "", fmt.Errorf("%w, expected %s", errSuffix, compressedSuffix)
+		}
+		return strings.TrimSuffix(srcPath, compressedSuffix), nil
+	}
+	if hasSuffix {
+		return "", fmt.Errorf("%w, file already has %s suffix", errSuffix, compressedSuffix)
+	}
+	return srcPath + compressedSuffix, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+)
+
+const (
+	msgTableNotLoaded = "(⁎˃ᆺ˂) table '%s' can not be loaded: %s\n"
+	msgDictNotLoaded  = "(⁎˃ᆺ˂) dictionary '%s' can not be loaded: %s\n"
+	msgBadStrategy    = "(⁎˃ᆺ˂) %s, expected one of %s\n"
+	msgBadAlgorithm   = "(⁎˃ᆺ˂) %s, expected one of %s\n"
+	msgBadLZWReset    = "(⁎˃ᆺ˂) %s, expected reset or freeze\n"
+	msgBadEntropy     = "(⁎˃ᆺ˂) %s, expected huffman or tans\n"
+	msgBadFilters     = "(⁎˃ᆺ˂) %s\n"
+	msgKeyNotLoaded   = "(⁎˃ᆺ˂) key '%s' can not be loaded: %s\n"
+	msgKeyMissing     = "(⁎˃ᆺ˂) encryption needs -key, -passphrase-file or %s variable\n"
+	msgGcmpOnlyFlag   = "(⁎˃ᆺ˂) -%s flag is supported only by %s algorithm\n"
+	passphraseEnv     = "GOCMP_PASSPHRASE"
+)
+
+var (
+	algorithm = flag.String("algo", internal.DefaultAlgorithm,
+		"compression algorithm: "+internal.AlgorithmNames())
+	tablePath = flag.String("table", "", "use shared huffman table from this file")
+	dictPath  = flag.String("dict", "", "prime LZ window with preset dictionary from this file")
+	strategy  = flag.String("strategy", internal.StrategyAuto.String(),
+		"compression strategy: "+internal.StrategyNames())
+	entropy = flag.String("entropy", internal.EntropyHuffman.String(),
+		"entropy coder of gcmp blocks: huffman or tans")
+	filters = flag.String("filter", "",
+		"comma separated filters applied before compression: delta, stride:N, x86")
+	seekIndex   = flag.Bool("index", false, "write seek index for random access decompression")
+	encrypt     = flag.Bool("encrypt", false, "encrypt compressed blocks with AES-256-GCM")
+	keyPath     = flag.String("key", "", "encryption key file")
+	passPath    = flag.String("passphrase-file", "", "file with encryption passphrase, "+passphraseEnv+" variable is used by default")
+	blockSync   = flag.Bool("sync", false, "write block sync markers and checksums needed for recovery")
+	maxSize     = flag.Int64("max-size", 0, "fail decompression producing more bytes than this, 0 means no limit")
+	maxRatio    = flag.Float64("max-ratio", 0, "fail decompression expanding input more times than this, 0 means no limit")
+	levels      = levelFlags()
+	lzwMaxWidth = flag.Int("lzw-bits", internal.LZWDefaultMaxWidth,
+		fmt.Sprintf("maximal lzw code width in bits, from %d to %d", internal.LZWMinMaxWidth, internal.LZWMaxMaxWidth))
+	lzwReset = flag.String("lzw-reset", internal.LZWReset.String(),
+		"what to do with full lzw dictionary: reset or freeze")
+)
+
+// levelFlags defines -1 (fastest) ... -9 (best) flags.
+func levelFlags() []*bool {
+	flags := make([]*bool, internal.MaxLevel+1)
+	for level := internal.MinLevel; level <= internal.MaxLevel; level++ {
+		flags[level] = flag.Bool(strconv.Itoa(level), false,
+			fmt.Sprintf("compression level %d", level))
+	}
+	return flags
+}
+
+func selectedLevel() int {
+	for level := internal.MaxLevel; level >= internal.MinLevel; level-- {
+		if *levels[level] {
+			return level
+		}
+	}
+	return internal.DefaultLevel
+}
+
+func encoderOptions() internal.Options {
+	s, err := internal.ParseStrategy(*strategy)
+	if err != nil {
+		fmt.Printf(msgBadStrategy, err, internal.StrategyNames())
+		os.Exit(-1)
+	}
+	e, err := internal.ParseEntropy(*entropy)
+	if err != nil {
+		fmt.Printf(msgBadEntropy, err)
+		os.Exit(-1)
+	}
+	fs, err := internal.ParseFilters(*filters)
+	if err != nil {
+		fmt.Printf(msgBadFilters, err)
+		os.Exit(-1)
+	}
+	policy, err := internal.ParseLZWResetPolicy(*lzwReset)
+	if err != nil {
+		fmt.Printf(msgBadLZWReset, err)
+		os.Exit(-1)
+	}
+	opts := internal.Options{
+		Level:         selectedLevel(),
+		Strategy:      s,
+		Entropy:       e,
+		Filters:       fs,
+		SeekIndex:     *seekIndex,
+		Sync:          *blockSync,
+		SingleMember:  *singleMember,
+		MaxOutputSize: *maxSize,
+		MaxRatio:      *maxRatio,
+		LZWMaxWidth:   *lzwMaxWidth,
+		LZWReset:      policy,
+	}
+	if *tablePath != "" {
+		if opts.Table, err = loadTable(*tablePath); err != nil {
+			fmt.Printf(msgTableNotLoaded, filepath.Base(*tablePath), err)
+			os.Exit(-1)
+		}
+	}
+	if *dictPath != "" {
+		if opts.Dictionary, err = os.ReadFile(*dictPath); err != nil {
+			fmt.Printf(msgDictNotLoaded, filepath.Base(*dictPath), err)
+			os.Exit(-1)
+		}
+	}
+	if !*decompressMode && *algorithm != internal.DefaultAlgorithm {
+		checkGcmpOnlyFlags()
+	}
+	if *encrypt || *decompressMode {
+		loadSecrets(&opts, *keyPath, *passPath, *encrypt)
+	}
+	return opts
+}
+
+// gcmpOnlyFlags configure block format and are ignored by raw codecs.
+var gcmpOnlyFlags = []string{"strategy", "entropy", "index", "encrypt", "sync"}
+
+// checkGcmpOnlyFlags fails if block format flags are set with raw codec.
+func checkGcmpOnlyFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		_, err := strconv.Atoi(f.Name)
+		if slices.Contains(gcmpOnlyFlags, f.Name) || err == nil {
+			fmt.Printf(msgGcmpOnlyFlag, f.Name, internal.DefaultAlgorithm)
+			os.Exit(-1)
+		}
+	})
+}
+
+// loadSecrets sets key or passphrase of options from files or environment.
+func loadSecrets(opts *internal.Options, keyPath, passPath string, required bool) {
+	var err error
+	switch {
+	case keyPath != "":
+		if opts.Key, err = os.ReadFile(keyPath); err != nil {
+			fmt.Printf(msgKeyNotLoaded, filepath.Base(keyPath), err)
+			os.Exit(-1)
+		}
+	case passPath != "":
+		passphrase, err := os.ReadFile(passPath)
+		if err != nil {
+			fmt.Printf(msgKeyNotLoaded, filepath.Base(passPath), err)
+			os.Exit(-1)
+		}
+		opts.Passphrase = bytes.TrimRight(passphrase, "\r\n")
+	case os.Getenv(passphraseEnv) != "":
+		opts.Passphrase = []byte(os.Getenv(passphraseEnv))
+	case required:
+		fmt.Printf(msgKeyMissing, passphraseEnv)
+		os.Exit(-1)
+	}
+}
+
+// checkAlgorithm exits if algorithm of -algo flag is unknown.
+func checkAlgorithm(opts internal.Options) {
+	if _, err := internal.NewEncoderDecoder(*algorithm, opts); err != nil {
+		fmt.Printf(msgBadAlgorithm, err, internal.AlgorithmNames())
+		os.Exit(-1)
+	}
+}
+
+func newEncoderDecoder(opts internal.Options, metadata *internal.FileMetadata) internal.EncoderDecoder {
+	opts.Metadata = metadata
+	enc, _ := internal.NewEncoderDecoder(*algorithm, opts)
+	return enc
+}
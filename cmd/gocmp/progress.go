@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"go-compressor/internal"
+	"os"
+	"strings"
+)
+
+const (
+	msgProgress      = "\r(=^･ω･^=) %-8s [%-*s] %3d%%"
+	progressBarWidth = 30
+)
+
+// progressShown tells whether progress is printed on stderr, which happens
+// only when stderr is a terminal.
+func progressShown() bool {
+	if *quiet || *jsonOutput {
+		return false
+	}
+	stat, err := os.Stderr.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar returns function printing percentage of input of given size
+// read by stage.
+func progressBar(size int64) internal.ProgressFunc {
+	lastStage, lastPercent := internal.Stage(-1), -1
+	return func(stage internal.Stage, read int64) {
+		percent := 100
+		if size > 0 {
+			percent = int(min(100*read/size, 100))
+		}
+		if stage == lastStage && percent == lastPercent {
+			return
+		}
+		lastStage, lastPercent = stage, percent
+		bar := strings.Repeat("=", percent*progressBarWidth/100)
+		fmt.Fprintf(os.Stderr, msgProgress, stage, progressBarWidth, bar, percent)
+	}
+}
+
+// clearProgress erases progress line.
+func clearProgress() {
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"os"
+	"path/filepath"
+)
+
+const (
+	msgRecoverArgsMissing = "(⁎˃ᆺ˂) damaged and (or) output files are missing\n"
+	msgRecoverFailed      = "(⁎˃ᆺ˂) can not recover: %s\n"
+	msgRecoverSuccess     = "(=^ ◡ ^=) recovered %d blocks (%d bytes) to file '%s'\n"
+	msgRecoverHeaderLost  = "(ᵕ—ᴗ—) stream header is lost, filters are not reverted\n"
+	msgRecoverLost        = "(ᵕ—ᴗ—) lost bytes %d-%d\n"
+	msgRecoverLostTail    = "(ᵕ—ᴗ—) lost bytes from %d till the end\n"
+	recoverCommand        = "recover"
+)
+
+func runRecover(args []string) {
+	fset := flag.NewFlagSet(recoverCommand, flag.ExitOnError)
+	table := fset.String("table", "", "shared huffman table used for compression")
+	dict := fset.String("dict", "", "preset dictionary used for compression")
+	key := fset.String("key", "", "encryption key file")
+	pass := fset.String("passphrase-file", "", "file with encryption passphrase")
+	_ = fset.Parse(args)
+
+	if fset.NArg() != 2 {
+		fmt.Print(msgRecoverArgsMissing)
+		os.Exit(-1)
+	}
+	srcPath, dstPath := fset.Arg(0), fset.Arg(1)
+
+	var opts internal.Options
+	var err error
+	if *table != "" {
+		if opts.Table, err = loadTable(*table); err != nil {
+			fmt.Printf(msgTableNotLoaded, filepath.Base(*table), err)
+			os.Exit(-1)
+		}
+	}
+	if *dict != "" {
+		if opts.Dictionary, err = os.ReadFile(*dict); err != nil {
+			fmt.Printf(msgDictNotLoaded, filepath.Base(*dict), err)
+			os.Exit(-1)
+		}
+	}
+
+	loadSecrets(&opts, *key, *pass, false)
+
+	inf, err := os.Open(srcPath)
+	if err != nil {
+		fmt.Printf(msgSrcFileNotOpen, filepath.Base(srcPath), err)
+		os.Exit(-1)
+	}
+	defer inf.Close()
+	outf, err := os.Create(dstPath)
+	if err != nil {
+		fmt.Printf(msgDstFileNotCreated, filepath.Base(dstPath), err)
+		os.Exit(-1)
+	}
+	defer outf.Close()
+
+	report, err := internal.Recover(inf, outf, opts)
+	if err != nil {
+		fmt.Printf(msgRecoverFailed, err)
+		_ = os.Remove(dstPath)
+		os.Exit(-1)
+	}
+	fmt.Printf(msgRecoverSuccess, report.Blocks, report.Bytes, filepath.Base(dstPath))
+	if report.HeaderLost {
+		fmt.Print(msgRecoverHeaderLost)
+	}
+	for _, lost := range report.Lost {
+		if lost.End < 0 {
+			fmt.Printf(msgRecoverLostTail, lost.Start)
+		} else {
+			fmt.Printf(msgRecoverLost, lost.Start, lost.End)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	msgWalkFailed       = "(⁎˃ᆺ˂) directory '%s' can not be walked: %s\n"
+	msgFileCompressed   = "( ^..^)ﾉ  %s -> %s, compression rate is %.2f\n"
+	msgFileFailed       = "(⁎˃ᆺ˂) %s: %s"
+	msgRecursiveSummary = "(=^ ◡ ^=) processed %d files, %d failed, compression rate is %.2f\n"
+)
+
+var (
+	recursive = flag.Bool("r", false, "process regular files of directories recursively, "+
+		"alongside originals or in mirror tree of destination directory")
+	workers  = flag.Int("workers", runtime.NumCPU(), "number of files processed in parallel with -r")
+	includes patternsFlag
+	excludes patternsFlag
+)
+
+func init() {
+	flag.Var(&includes, "include", "process only files matching this glob with -r, may be repeated")
+	flag.Var(&excludes, "exclude", "skip files matching this glob with -r, may be repeated")
+}
+
+// patternsFlag collects glob patterns of repeated flag.
+type patternsFlag []string
+
+func (pf *patternsFlag) String() string {
+	return strings.Join(*pf, ",")
+}
+
+func (pf *patternsFlag) Set(pattern string) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return err
+	}
+	*pf = append(*pf, pattern)
+	return nil
+}
+
+// match tells whether any pattern matches base name or slash separated path
+// relative to walked directory.
+func (pf patternsFlag) match(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range pf {
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type fileJob struct {
+	srcPath, dstPath string
+}
+
+type jobResult struct {
+	job fileJob
+	res *fileResult
+	err error
+}
+
+// runRecursive processes files of directory args[0], writing outputs to the
+// mirror tree of args[1] if given. It returns false if any file failed.
+func runRecursive(args []string, opts internal.Options) bool {
+	root := args[0]
+	outRoot := ""
+	if len(args) == 2 {
+		outRoot = args[1]
+	}
+	jobs, err := collectJobs(root, outRoot)
+	if err != nil {
+		fmt.Printf(msgWalkFailed, root, err)
+		return false
+	}
+
+	jobc := make(chan fileJob)
+	results := make(chan jobResult)
+	var wg sync.WaitGroup
+	for i := 0; i < max(*workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobc {
+				res, err := processJob(job, outRoot == "", opts)
+				results <- jobResult{job: job, res: res, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, job := range jobs {
+			jobc <- job
+		}
+		close(jobc)
+		wg.Wait()
+		close(results)
+	}()
+
+	var failed int
+	var total fileResult
+	for r := range results {
+		if *jsonOutput && printJSON(r.job.srcPath, r.res, r.err) != nil && r.err == nil {
+			failed++
+			continue
+		}
+		if r.err != nil {
+			failed++
+			if !*jsonOutput {
+				fmt.Printf(msgFileFailed, r.job.srcPath, r.err)
+			}
+			continue
+		}
+		say(msgFileCompressed, r.job.srcPath, r.res.dstPath, r.res.rate())
+		total.inSize += r.res.inSize
+		total.outSize += r.res.outSize
+	}
+	say(msgRecursiveSummary, len(jobs), failed, total.rate())
+	return failed == 0
+}
+
+func processJob(job fileJob, inPlace bool, opts internal.Options) (*fileResult, error) {
+	if err := os.MkdirAll(filepath.Dir(job.dstPath), 0o755); err != nil {
+		return nil, fail(msgDstFileNotCreated, filepath.Base(job.dstPath), err)
+	}
+	return processFile(job.srcPath, job.dstPath, inPlace, opts)
+}
+
+// collectJobs walks root and returns regular files to process with their
+// destinations. Compressed files are skipped on compression, and other
+// files are skipped on decompression.
+func collectJobs(root, outRoot string) ([]fileJob, error) {
+	var jobs []fileJob
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if len(includes) > 0 && !includes.match(rel) || excludes.match(rel) {
+			return nil
+		}
+		if strings.HasSuffix(path, compressedSuffix) != *decompressMode {
+			return nil
+		}
+		dstPath, err := defaultOutputPath(path, *decompressMode)
+		if err != nil {
+			return nil
+		}
+		if outRoot != "" {
+			dstRel, err := filepath.Rel(root, dstPath)
+			if err != nil {
+				return err
+			}
+			dstPath = filepath.Join(outRoot, dstRel)
+		}
+		jobs = append(jobs, fileJob{srcPath: path, dstPath: dstPath})
+		return nil
+	})
+	return jobs, err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// msgErrorPrefix starts error messages and is trimmed in JSON.
+	msgErrorPrefix  = "(⁎˃ᆺ˂) "
+	msgReportFailed = "(⁎˃ᆺ˂) report of file '%s' can not be encoded: %s\n"
+)
+
+var (
+	jsonOutput = flag.Bool("json", false, "print JSON object per file instead of messages")
+	quiet      = flag.Bool("q", false, "print nothing on success")
+)
+
+// fileReport is JSON description of processed file.
+type fileReport struct {
+	Input       string  `json:"input"`
+	Output      string  `json:"output,omitempty"`
+	Mode        string  `json:"mode"`
+	Algorithm   string  `json:"algorithm"`
+	InputSize   int64   `json:"input_size,omitempty"`
+	OutputSize  int64   `json:"output_size,omitempty"`
+	Ratio       float64 `json:"ratio,omitempty"`
+	BitsPerByte float64 `json:"bits_per_byte,omitempty"`
+	Duration    float64 `json:"duration_seconds,omitempty"`
+	Throughput  float64 `json:"throughput_mb_per_second,omitempty"`
+	SHA256      string  `json:"output_sha256,omitempty"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// say prints informational message unless output is quiet or JSON.
+func say(format string, args ...any) {
+	if !*quiet && !*jsonOutput {
+		fmt.Printf(format, args...)
+	}
+}
+
+// warn prints message about non-fatal problem, to stderr in JSON mode.
+func warn(format string, args ...any) {
+	switch {
+	case *quiet:
+	case *jsonOutput:
+		fmt.Fprintf(os.Stderr, format, args...)
+	default:
+		fmt.Printf(format, args...)
+	}
+}
+
+// printJSON prints report of file processed with given result or error. If
+// report can not be encoded, message is printed to stderr instead.
+func printJSON(srcPath string, res *fileResult, err error) error {
+	report := fileReport{Input: srcPath, Mode: "compress", Algorithm: *algorithm}
+	if *decompressMode {
+		report.Mode = "decompress"
+	}
+	if err != nil {
+		report.Error = strings.TrimSpace(strings.TrimPrefix(err.Error(), msgErrorPrefix))
+	} else {
+		report.Output = res.dstPath
+		report.InputSize, report.OutputSize = res.inSize, res.outSize
+		report.Ratio = res.rate()
+		report.BitsPerByte = res.bitsPerByte()
+		report.Duration = res.duration.Seconds()
+		if report.Duration > 0 {
+			report.Throughput = float64(res.inSize) / 1e6 / report.Duration
+		}
+		report.SHA256 = fmt.Sprintf("%x", res.checksum)
+	}
+	out, err := json.Marshal(report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, msgReportFailed, srcPath, err)
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
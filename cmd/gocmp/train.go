@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-compressor/internal"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	msgTrainArgsMissing  = "(⁎˃ᆺ˂) output table and (or) sample files are missing\n"
+	msgTrainFailed       = "(⁎˃ᆺ˂) can not train table: %s\n"
+	msgTrainSuccess      = "(=^ ◡ ^=) successfully trained table '%s' on %d files\n"
+	msgTableID           = "( ^..^)ﾉ  table id is %08x\n"
+	trainCommand         = "train"
+	trainOutputFlagUsage = "write trained table to this file"
+)
+
+func runTrain(args []string) {
+	fset := flag.NewFlagSet(trainCommand, flag.ExitOnError)
+	output := fset.String("o", "", trainOutputFlagUsage)
+	_ = fset.Parse(args)
+
+	if *output == "" || fset.NArg() == 0 {
+		fmt.Print(msgTrainArgsMissing)
+		os.Exit(-1)
+	}
+
+	samples, err := collectSamples(fset.Args())
+	if err != nil {
+		fmt.Printf(msgTrainFailed, err)
+		os.Exit(-1)
+	}
+	readers := make([]io.Reader, 0, len(samples))
+	for _, path := range samples {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Printf(msgSrcFileNotOpen, filepath.Base(path), err)
+			os.Exit(-1)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	table, err := internal.TrainHuffmanTable(readers...)
+	if err != nil {
+		fmt.Printf(msgTrainFailed, err)
+		os.Exit(-1)
+	}
+
+	outf, err := os.Create(*output)
+	if err != nil {
+		fmt.Printf(msgDstFileNotCreated, filepath.Base(*output), err)
+		os.Exit(-1)
+	}
+	defer outf.Close()
+	if err := table.Save(outf); err != nil {
+		fmt.Printf(msgTrainFailed, err)
+		_ = os.Remove(*output)
+		os.Exit(-1)
+	}
+	fmt.Printf(msgTrainSuccess, filepath.Base(*output), len(samples))
+	fmt.Printf(msgTableID, table.ID())
+}
+
+// collectSamples expands directories to regular files inside them.
+func collectSamples(paths []string) ([]string, error) {
+	var samples []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				samples = append(samples, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return samples, nil
+}
+
+func loadTable(path string) (*internal.HuffmanTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return internal.LoadHuffmanTable(f)
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultAlgorithm is block format, other algorithms produce raw streams of
+// their codecs, which are not recognized by BlockEncoderDecoder. With filters
+// raw stream is preceded by them.
+const DefaultAlgorithm = "gcmp"
+
+// algorithms build codecs with their exported constructors, progress of
+// raw codecs is set by NewEncoderDecoder.
+var algorithms = map[string]func(Options) EncoderDecoder{
+	DefaultAlgorithm: NewBlockEncoderDecoder,
+	"huffman": func(opts Options) EncoderDecoder {
+		return NewHuffmanEncoderDecoderWithTable(opts.Table)
+	},
+	"lz": func(opts Options) EncoderDecoder {
+		return NewLZEncoderDecoder(opts.Dictionary)
+	},
+	"lzw": func(opts Options) EncoderDecoder {
+		return NewLZWEncoderDecoder(opts.LZWMaxWidth, opts.LZWReset)
+	},
+	"rle": func(opts Options) EncoderDecoder {
+		return NewRLEEncoderDecoder()
+	},
+}
+
+func NewEncoderDecoder(algorithm string, opts Options) (EncoderDecoder, error) {
+	if newED, ok := algorithms[algorithm]; ok {
+		ed := newED(opts)
+		if ps, ok := ed.(progressSetter); ok {
+			ps.setProgress(opts.Progress)
+		}
+		if algorithm != DefaultAlgorithm && len(opts.Filters) > 0 {
+			ed = &filteredCodec{EncoderDecoder: ed, filters: opts.Filters}
+		}
+		if algorithm != DefaultAlgorithm && opts.limited() {
+			ed = &limitedDecoder{EncoderDecoder: ed, opts: opts}
+		}
+		return ed, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm '%s'", algorithm)
+}
+
+// AlgorithmNames lists algorithms accepted by NewEncoderDecoder.
+func AlgorithmNames() string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ",")
+}
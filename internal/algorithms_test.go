@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewEncoderDecoder(t *testing.T) {
+	input := []byte(strings.Repeat("algorithm\x00\x00\x00\x00\x00\x00", 100))
+	for _, name := range strings.Split(AlgorithmNames(), ",") {
+		t.Run(name, func(t *testing.T) {
+			ed, err := NewEncoderDecoder(name, Options{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			var encoded, decoded bytes.Buffer
+			if err := ed.Encode(bytes.NewReader(input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if err := ed.Decode(&encoded, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+	if _, err := NewEncoderDecoder("zip", Options{}); err == nil {
+		t.Errorf("Unknown algorithm is created without error")
+	}
+}
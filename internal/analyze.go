@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"io"
+	"math"
+)
+
+// SymbolStats describes single byte of analyzed input. IdealLength is its
+// information content in bits.
+type SymbolStats struct {
+	Symbol      byte    `json:"symbol"`
+	Frequency   uint64  `json:"frequency"`
+	IdealLength float64 `json:"ideal_length"`
+	CodeLength  int     `json:"code_length"`
+}
+
+// Analysis tells how well input suits huffman coding. Entropy and average
+// code length are in bits per byte, sizes are in bytes. TreeSize is overhead
+// of serialized tree and stored input size. CompressedSize is size of huffman
+// stream, which may be stored input.
+type Analysis struct {
+	Size           uint64        `json:"size"`
+	Entropy        float64       `json:"entropy"`
+	MinSize        float64       `json:"min_size"`
+	AverageCode    float64       `json:"average_code_length"`
+	CodesSize      uint64        `json:"codes_size"`
+	TreeSize       uint64        `json:"tree_size"`
+	CompressedSize uint64        `json:"compressed_size"`
+	Symbols        []SymbolStats `json:"symbols"`
+}
+
+// Analyze counts byte frequencies of r and builds huffman tree the same way
+// huffman algorithm does.
+func Analyze(r io.Reader) (*Analysis, error) {
+	fa, err := newFrequencyArray(r)
+	if err != nil {
+		return nil, err
+	}
+	ht := newHuffmanTree(newForest(fa))
+
+	a := &Analysis{Size: fa.total()}
+	for b := 0; b < bytesCount; b++ {
+		freq := fa.frequencyOf(byte(b))
+		if freq == 0 {
+			continue
+		}
+		p := float64(freq) / float64(fa.total())
+		// log of inverse probability is +0 for the only symbol, not -0
+		s := SymbolStats{
+			Symbol:      byte(b),
+			Frequency:   freq,
+			IdealLength: math.Log2(float64(fa.total()) / float64(freq)),
+			CodeLength:  len(ht.charEncoding(byte(b))),
+		}
+		a.Entropy += p * s.IdealLength
+		a.Symbols = append(a.Symbols, s)
+	}
+	a.MinSize = a.Entropy * float64(a.Size) / byteBits
+	a.CodesSize = (ht.encodedBits(fa) + byteBits - 1) / byteBits
+	a.TreeSize = treeSize(ht, nil)
+	// incompressible input is stored as huffman encoder does
+	a.CompressedSize = min(a.TreeSize+a.CodesSize, storedHeaderSize+a.Size)
+	if a.Size > 0 {
+		a.AverageCode = float64(ht.encodedBits(fa)) / float64(a.Size)
+	}
+	return a, nil
+}
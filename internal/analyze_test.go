@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	a, err := Analyze(strings.NewReader("aaaabbcd"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Size != 8 || len(a.Symbols) != 4 {
+		t.Fatalf("Invalid size %d or # of symbols %d", a.Size, len(a.Symbols))
+	}
+	// dyadic frequencies are coded exactly at entropy
+	if math.Abs(a.Entropy-1.75) > 1e-9 || math.Abs(a.AverageCode-1.75) > 1e-9 {
+		t.Errorf("Expected entropy and average code length 1.75, got %f and %f", a.Entropy, a.AverageCode)
+	}
+	for i, expected := range []int{1, 2, 3, 3} {
+		if a.Symbols[i].CodeLength != expected {
+			t.Errorf("Invalid code length of '%c'. Expected %d, got %d",
+				a.Symbols[i].Symbol, expected, a.Symbols[i].CodeLength)
+		}
+	}
+	if a.TreeSize != 2+7*huffmanNodeSize+8 || a.CodesSize != 2 {
+		t.Errorf("Invalid tree size %d or codes size %d", a.TreeSize, a.CodesSize)
+	}
+}
+
+func TestAnalyzeEmpty(t *testing.T) {
+	a, err := Analyze(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if a.Size != 0 || a.Entropy != 0 || len(a.Symbols) != 0 {
+		t.Errorf("Expected empty analysis, got %+v", a)
+	}
+}
+
+func TestAnalyzeCompressedSize(t *testing.T) {
+	random := make([]byte, 1<<12)
+	rand.New(rand.NewSource(7)).Read(random)
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "SingleSymbol", input: []byte("aaaaaaaa")},
+		{name: "Text", input: []byte(strings.Repeat("analysis of text ", 100))},
+		{name: "Random", input: random},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Analyze(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			for _, s := range a.Symbols {
+				if s.IdealLength < 0 || math.Signbit(s.IdealLength) {
+					t.Errorf("Negative ideal length %f of '%c'", s.IdealLength, s.Symbol)
+				}
+			}
+			var encoded bytes.Buffer
+			if err := NewHuffmanEncoderDecoder().Encode(bytes.NewReader(tt.input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if a.CompressedSize != uint64(encoded.Len()) {
+				t.Errorf("Expected compressed size %d, got %d", encoded.Len(), a.CompressedSize)
+			}
+		})
+	}
+}
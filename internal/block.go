@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"go-compressor/pkg/bits"
+)
+
+// Block method byte keeps transform in low nibble and entropy coder in high
+// nibble. Zero method marks the end of stream.
+const (
+	transformNone byte = iota
+	transformRLE
+	transformLZ
+	transformBWT
+
+	transformMask = 0x0f
+)
+
+const (
+	entropyHuffman byte = (iota + 1) << 4
+	// entropyStored keeps transformed bytes as is, transformNone with it
+	// stores incompressible block.
+	entropyStored
+	entropyTANS
+
+	entropyMask = 0xf0
+)
+
+const (
+	blockEnd = 0
+
+	// maxBlockSize bounds block sizes accepted by decoder, and maxStageSize
+	// bounds intermediate results, which may be larger than raw data.
+	maxBlockSize = 1 << 20
+	maxStageSize = 2*maxBlockSize + 64
+)
+
+var strategyTransforms = map[Strategy]byte{
+	StrategyHuffmanOnly: transformNone,
+	StrategyRLE:         transformRLE,
+	StrategyLZ:          transformLZ,
+	StrategyBWT:         transformBWT,
+}
+
+// autoCandidates are strategies tried by StrategyAuto.
+var autoCandidates = []Strategy{StrategyHuffmanOnly, StrategyRLE, StrategyLZ, StrategyBWT}
+
+type blockCodec struct {
+	params  levelParams
+	entropy Entropy
+	table   *HuffmanTable
+	dict    []byte
+	filters []Filter
+
+	// aead encrypts blocks with stream header as additional data.
+	aead   cipher.AEAD
+	header []byte
+
+	// huffman is reused by blocks of the stream.
+	huffman *huffmanScratch
+}
+
+func newBlockCodec(opts *Options) *blockCodec {
+	return &blockCodec{
+		params:  opts.levelParams(),
+		entropy: opts.Entropy,
+		table:   opts.Table,
+		dict:    opts.Dictionary,
+		filters: opts.Filters,
+	}
+}
+
+func (bc *blockCodec) applyFilters(block []byte) {
+	applyFilters(bc.filters, block)
+}
+
+func (bc *blockCodec) revertFilters(block []byte) {
+	revertFilters(bc.filters, block)
+}
+
+// compressBlock appends compressed src to dst and returns method it used.
+// Block is stored, when it can not be compressed.
+func (bc *blockCodec) compressBlock(dst, src []byte, s Strategy) ([]byte, byte, error) {
+	start := len(dst)
+	dst, method, err := bc.compressWithStrategy(dst, src, s)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(dst)-start >= len(src) {
+		return append(dst[:start], src...), transformNone | entropyStored, nil
+	}
+	return dst, method, nil
+}
+
+func (bc *blockCodec) compressWithStrategy(dst, src []byte, s Strategy) ([]byte, byte, error) {
+	if s == StrategyAuto {
+		s = bc.chooseStrategy(src)
+	}
+	if s == StrategyAuto {
+		return bc.compressBest(dst, src)
+	}
+	transform, ok := strategyTransforms[s]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown strategy %s", s)
+	}
+	dst, entropy, err := bc.entropyCompress(dst, bc.applyTransform(transform, src))
+	return dst, transform | entropy, err
+}
+
+func (bc *blockCodec) entropyCompress(dst, stage []byte) ([]byte, byte, error) {
+	start := len(dst)
+	entropy := entropyHuffman
+	var err error
+	if bc.entropy == EntropyTANS {
+		entropy = entropyTANS
+		dst, err = tansCompress(dst, stage)
+	} else {
+		dst, err = bc.huffmanCompress(dst, stage)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(dst)-start >= len(stage) {
+		return append(dst[:start], stage...), entropyStored, nil
+	}
+	return dst, entropy, nil
+}
+
+// chooseStrategy compresses sample of src with every candidate strategy.
+// It returns StrategyAuto, when whole block should be tried instead.
+func (bc *blockCodec) chooseStrategy(src []byte) Strategy {
+	if bc.params.exhaustive || len(src) <= bc.params.sampleSize {
+		return StrategyAuto
+	}
+	const chunks = 4
+	chunkSize := bc.params.sampleSize / chunks
+	sample := make([]byte, 0, bc.params.sampleSize)
+	for i := 0; i < chunks; i++ {
+		start := i * (len(src) - chunkSize) / (chunks - 1)
+		sample = append(sample, src[start:start+chunkSize]...)
+	}
+
+	best, bestSize := StrategyHuffmanOnly, -1
+	for _, s := range autoCandidates {
+		out, _, err := bc.compressBlock(nil, sample, s)
+		if err == nil && (bestSize < 0 || len(out) < bestSize) {
+			best, bestSize = s, len(out)
+		}
+	}
+	return best
+}
+
+func (bc *blockCodec) compressBest(dst, src []byte) ([]byte, byte, error) {
+	var best []byte
+	var bestMethod byte
+	for _, s := range autoCandidates {
+		out, method, err := bc.compressBlock(nil, src, s)
+		if err != nil {
+			return nil, 0, err
+		}
+		if best == nil || len(out) < len(best) {
+			best, bestMethod = out, method
+		}
+	}
+	return append(dst, best...), bestMethod, nil
+}
+
+func (bc *blockCodec) applyTransform(transform byte, src []byte) []byte {
+	switch transform {
+	case transformRLE:
+		return rleEncode(nil, src)
+	case transformLZ:
+		return lzCompress(nil, src, bc.dict, bc.params.lzChain)
+	case transformBWT:
+		return bwtRLEEncode(src)
+	default:
+		return src
+	}
+}
+
+func (bc *blockCodec) decompressBlock(dst []byte, method byte, payload []byte, rawSize int) ([]byte, error) {
+	var stage []byte
+	var err error
+	switch method & entropyMask {
+	case entropyHuffman:
+		stage, err = bc.huffmanDecompress(payload)
+	case entropyStored:
+		stage = payload
+	case entropyTANS:
+		stage, err = tansDecompress(payload)
+	default:
+		err = fmt.Errorf("%w: unknown block method %02x", ErrCorruptedStream, method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	start := len(dst)
+	switch method & transformMask {
+	case transformNone:
+		dst = append(dst, stage...)
+	case transformRLE:
+		dst, err = rleDecode(dst, stage, rawSize)
+	case transformLZ:
+		buf := bytes.NewBuffer(dst)
+		err = lzDecompress(bytes.NewReader(stage), buf, bc.dict, uint64(rawSize))
+		dst = buf.Bytes()
+	case transformBWT:
+		dst, err = bwtRLEDecode(dst, stage, rawSize)
+	default:
+		err = fmt.Errorf("%w: unknown block method %02x", ErrCorruptedStream, method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(dst)-start != rawSize {
+		return nil, fmt.Errorf("%w: block size mismatch", ErrCorruptedStream)
+	}
+	return dst, nil
+}
+
+// bwtRLEEncode moves equal bytes together with BWT, turns them into zero
+// runs with MTF, and shortens runs with RLE.
+func bwtRLEEncode(src []byte) []byte {
+	transformed := bwtEncode(nil, src)
+	_, sz := binary.Uvarint(transformed)
+	out := append([]byte(nil), transformed[:sz]...)
+	return rleEncode(out, mtfEncode(nil, transformed[sz:]))
+}
+
+// bwtRLEDecode appends at most size bytes decoded from src to dst.
+func bwtRLEDecode(dst, src []byte, size int) ([]byte, error) {
+	_, sz := binary.Uvarint(src)
+	if sz <= 0 {
+		return nil, fmt.Errorf("%w: invalid bwt index", ErrCorruptedStream)
+	}
+	moved, err := rleDecode(nil, src[sz:], size)
+	if err != nil {
+		return nil, err
+	}
+	transformed := append(append([]byte(nil), src[:sz]...), mtfDecode(nil, moved)...)
+	return bwtDecode(dst, transformed)
+}
+
+// huffmanCompress appends tree (or shared table reference), number of
+// symbols and their codes.
+func (bc *blockCodec) huffmanCompress(dst, src []byte) ([]byte, error) {
+	ht := bc.tableTree()
+	if ht == nil {
+		hs := bc.huffmanScratch()
+		if err := hs.buildTree(bytes.NewReader(src)); err != nil {
+			return nil, err
+		}
+		ht = &hs.tree
+	}
+	buf := bytes.NewBuffer(dst)
+	if err := writeTreeOrReference(buf, ht, bc.table); err != nil {
+		return nil, err
+	}
+	buf.Write(binary.AppendUvarint(nil, uint64(len(src))))
+	bitw := bits.NewBitWriter(buf)
+	if err := ht.encodeBytes(bytes.NewReader(src), bitw); err != nil {
+		return nil, err
+	}
+	if err := bitw.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (bc *blockCodec) huffmanScratch() *huffmanScratch {
+	if bc.huffman == nil {
+		bc.huffman = &huffmanScratch{}
+	}
+	return bc.huffman
+}
+
+func (bc *blockCodec) tableTree() *huffmanTree {
+	if bc.table == nil {
+		return nil
+	}
+	return bc.table.tree
+}
+
+func (bc *blockCodec) huffmanDecompress(payload []byte) ([]byte, error) {
+	r := bytes.NewReader(payload)
+	ht, err := readTreeOrReference(r, bc.table, &bc.huffmanScratch().tree)
+	if err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxStageSize {
+		return nil, fmt.Errorf("%w: stage of %d bytes", ErrCorruptedStream, n)
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, n))
+	if err := ht.decodeBytes(bits.NewBitReader(r), buf, n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// sortCyclicShifts returns start positions of src rotations in sorted order.
+// Rotations are sorted by prefixes of doubling length with counting sort.
+func sortCyclicShifts(src []byte) []int32 {
+	n := len(src)
+	p := make([]int32, n)
+	c := make([]int32, n)
+	cnt := make([]int32, max(bytesCount, n))
+	for _, b := range src {
+		cnt[b]++
+	}
+	for i := 1; i < bytesCount; i++ {
+		cnt[i] += cnt[i-1]
+	}
+	for i := n - 1; i >= 0; i-- {
+		cnt[src[i]]--
+		p[cnt[src[i]]] = int32(i)
+	}
+	classes := int32(1)
+	for i := 1; i < n; i++ {
+		if src[p[i]] != src[p[i-1]] {
+			classes++
+		}
+		c[p[i]] = classes - 1
+	}
+
+	pn := make([]int32, n)
+	cn := make([]int32, n)
+	for h := 1; h < n && int(classes) < n; h <<= 1 {
+		for i := range p {
+			pn[i] = (p[i] - int32(h) + int32(n)) % int32(n)
+		}
+		clear(cnt[:classes])
+		for _, pos := range pn {
+			cnt[c[pos]]++
+		}
+		for i := int32(1); i < classes; i++ {
+			cnt[i] += cnt[i-1]
+		}
+		for i := n - 1; i >= 0; i-- {
+			cnt[c[pn[i]]]--
+			p[cnt[c[pn[i]]]] = pn[i]
+		}
+		cn[p[0]] = 0
+		classes = 1
+		for i := 1; i < n; i++ {
+			cur, prev := p[i], p[i-1]
+			if c[cur] != c[prev] || c[(int(cur)+h)%n] != c[(int(prev)+h)%n] {
+				classes++
+			}
+			cn[cur] = classes - 1
+		}
+		c, cn = cn, c
+	}
+	return p
+}
+
+// bwtEncode appends index of src among its sorted rotations followed by
+// last column of rotations matrix.
+func bwtEncode(dst, src []byte) []byte {
+	n := len(src)
+	shifts := sortCyclicShifts(src)
+	var primary int
+	last := make([]byte, n)
+	for i, s := range shifts {
+		if s == 0 {
+			primary = i
+		}
+		last[i] = src[(int(s)+n-1)%n]
+	}
+	dst = binary.AppendUvarint(dst, uint64(primary))
+	return append(dst, last...)
+}
+
+func bwtDecode(dst, src []byte) ([]byte, error) {
+	primary, sz := binary.Uvarint(src)
+	if sz <= 0 {
+		return nil, fmt.Errorf("%w: invalid bwt index", ErrCorruptedStream)
+	}
+	last := src[sz:]
+	n := len(last)
+	if n == 0 {
+		return dst, nil
+	}
+	if primary >= uint64(n) {
+		return nil, fmt.Errorf("%w: invalid bwt index", ErrCorruptedStream)
+	}
+
+	var start [bytesCount]int
+	for _, b := range last {
+		start[b]++
+	}
+	for b, sum := 0, 0; b < bytesCount; b++ {
+		start[b], sum = sum, sum+start[b]
+	}
+	// next[i] is row of i-th row rotated by one to the left
+	next := make([]int32, n)
+	for i, b := range last {
+		next[start[b]] = int32(i)
+		start[b]++
+	}
+	for i, p := 0, next[primary]; i < n; i, p = i+1, next[p] {
+		dst = append(dst, last[p])
+	}
+	return dst, nil
+}
+
+func mtfEncode(dst, src []byte) []byte {
+	var order [bytesCount]byte
+	for i := range order {
+		order[i] = byte(i)
+	}
+	for _, b := range src {
+		j := 0
+		for order[j] != b {
+			j++
+		}
+		copy(order[1:j+1], order[:j])
+		order[0] = b
+		dst = append(dst, byte(j))
+	}
+	return dst
+}
+
+func mtfDecode(dst, src []byte) []byte {
+	var order [bytesCount]byte
+	for i := range order {
+		order[i] = byte(i)
+	}
+	for _, j := range src {
+		b := order[j]
+		copy(order[1:int(j)+1], order[:j])
+		order[0] = b
+		dst = append(dst, b)
+	}
+	return dst
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBWTEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Banana",
+			input:    "banana",
+			expected: "\x03nnbaaa",
+		},
+		{
+			name:     "Periodic",
+			input:    "abababab",
+			expected: "\x03bbbbaaaa",
+		},
+		{
+			name:     "OneByte",
+			input:    "a",
+			expected: "\x00a",
+		},
+		{
+			name:     "Empty",
+			input:    "",
+			expected: "\x00",
+		},
+		{
+			name:  "LongText",
+			input: strings.Repeat("the quick brown fox jumps over the lazy dog ", 500),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := bwtEncode(nil, []byte(tt.input))
+			if tt.expected != "" && string(encoded) != tt.expected {
+				t.Errorf("BWT differs: expected %q, got %q", tt.expected, encoded)
+			}
+			decoded, err := bwtDecode(nil, encoded)
+			if err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if string(decoded) != tt.input {
+				t.Errorf("Decoded data differs: expected %q, got %q", tt.input, decoded)
+			}
+		})
+	}
+}
+
+func TestMTFEncodeDecode(t *testing.T) {
+	input := []byte("bananaaa\x00\xff\xff")
+	encoded := mtfEncode(nil, input)
+	if expected := []byte{98, 98, 110, 1, 1, 1, 0, 0, 3, 255, 0}; !bytes.Equal(encoded, expected) {
+		t.Errorf("MTF differs: expected %v, got %v", expected, encoded)
+	}
+	if decoded := mtfDecode(nil, encoded); !bytes.Equal(decoded, input) {
+		t.Errorf("Decoded data differs: expected %q, got %q", input, decoded)
+	}
+}
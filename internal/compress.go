@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// blockBuffers are scratch buffers of block stream encoding and decoding,
+// reused through blockBuffersPool.
+type blockBuffers struct {
+	block, payload []byte
+	br             *bufio.Reader
+	bw             *bufio.Writer
+	huffman        huffmanScratch
+}
+
+var blockBuffersPool = sync.Pool{
+	New: func() any {
+		return &blockBuffers{
+			br: bufio.NewReaderSize(nil, BufferSize),
+			bw: bufio.NewWriterSize(nil, BufferSize),
+		}
+	},
+}
+
+func getBlockBuffers() *blockBuffers {
+	return blockBuffersPool.Get().(*blockBuffers)
+}
+
+// release returns buffers to the pool, dropping references to reader and
+// writer of the stream.
+func (bb *blockBuffers) release() {
+	bb.br.Reset(nil)
+	bb.bw.Reset(nil)
+	blockBuffersPool.Put(bb)
+}
+
+func (bb *blockBuffers) reader(r io.Reader) *bufio.Reader {
+	bb.br.Reset(r)
+	return bb.br
+}
+
+func (bb *blockBuffers) writer(w io.Writer) *bufio.Writer {
+	bb.bw.Reset(w)
+	return bb.bw
+}
+
+func (bb *blockBuffers) blockOf(size int) []byte {
+	if cap(bb.block) < size {
+		bb.block = make([]byte, size)
+	}
+	return bb.block[:size]
+}
+
+// appendWriter appends written bytes to buf.
+type appendWriter struct {
+	buf []byte
+}
+
+func (aw *appendWriter) Write(p []byte) (int, error) {
+	aw.buf = append(aw.buf, p...)
+	return len(p), nil
+}
+
+// Compress appends block stream of src, encoded with default options, to
+// dst and returns the extended buffer.
+func Compress(dst, src []byte) ([]byte, error) {
+	aw := &appendWriter{buf: dst}
+	bed := &BlockEncoderDecoder{}
+	if err := bed.Encode(bytes.NewReader(src), aw); err != nil {
+		return dst, err
+	}
+	return aw.buf, nil
+}
+
+// Decompress bounds output of src to decompressRatio times its size, but at
+// least to decompressMinLimit bytes.
+const (
+	decompressRatio    = 1 << 12
+	decompressMinLimit = 1 << 20
+)
+
+// Decompress appends data decoded from block stream src to dst and returns
+// the extended buffer. Output may be at most 4096 times larger than src, but
+// always up to 1 MiB, so that untrusted src can not exhaust memory. Larger
+// output fails with LimitError, DecompressWithOptions with zero limits
+// decodes streams of any ratio.
+func Decompress(dst, src []byte) ([]byte, error) {
+	limit := max(int64(len(src))*decompressRatio, decompressMinLimit)
+	return DecompressWithOptions(dst, src, Options{MaxOutputSize: limit})
+}
+
+// DecompressWithOptions is Decompress with limits, table, dictionary and
+// keys of opts. Zero limits of opts mean no limit.
+func DecompressWithOptions(dst, src []byte, opts Options) ([]byte, error) {
+	aw := &appendWriter{buf: dst}
+	bed := &BlockEncoderDecoder{opts: opts}
+	if err := bed.Decode(bytes.NewReader(src), aw); err != nil {
+		return dst, err
+	}
+	return aw.buf, nil
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Small", input: []byte(`{"id":42,"name":"gopher","tags":["a","b"]}`)},
+		{name: "Large", input: []byte(strings.Repeat("compressible text ", 1<<16))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := []byte("prefix")
+			compressed, err := Compress(append([]byte{}, prefix...), tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected compression error: %s", err)
+			}
+			if !bytes.HasPrefix(compressed, prefix) {
+				t.Fatalf("Compressed data is not appended to dst")
+			}
+			decompressed, err := Decompress(append([]byte{}, prefix...), compressed[len(prefix):])
+			if err != nil {
+				t.Fatalf("Unexpected decompression error: %s", err)
+			}
+			if !bytes.Equal(decompressed, append(prefix, tt.input...)) {
+				t.Fatalf("Initial and decompressed data are different")
+			}
+		})
+	}
+}
+
+func TestDecompressCorrupted(t *testing.T) {
+	compressed, _ := Compress(nil, []byte("some data to compress"))
+	compressed[0] ^= 0xff
+	dst := []byte("dst")
+	out, err := Decompress(dst, compressed)
+	if err == nil {
+		t.Fatalf("Expected decompression error")
+	}
+	if !bytes.Equal(out, dst) {
+		t.Errorf("Expected dst to be returned unchanged, got %q", out)
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			var dst []byte
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var err error
+				if dst, err = Compress(dst[:0], in.data); err != nil {
+					b.Fatalf("Unexpected compression error: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			compressed, _ := Compress(nil, in.data)
+			var dst []byte
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var err error
+				if dst, err = Decompress(dst[:0], compressed); err != nil {
+					b.Fatalf("Unexpected decompression error: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecompressBomb(t *testing.T) {
+	var le *LimitError
+	out, err := Decompress(nil, huffmanBomb(1<<28))
+	if !errors.As(err, &le) || len(out) != 0 {
+		t.Fatalf("Expected limit error, got %d bytes and %v", len(out), err)
+	}
+	if le.MaxOutputSize != decompressMinLimit {
+		t.Errorf("Expected default limit %d, got %d", decompressMinLimit, le.MaxOutputSize)
+	}
+
+	_, err = DecompressWithOptions(nil, huffmanBomb(1<<28), Options{MaxOutputSize: 1 << 16})
+	if !errors.As(err, &le) || le.MaxOutputSize != 1<<16 {
+		t.Fatalf("Expected limit error, got %v", err)
+	}
+	out, err = DecompressWithOptions(nil, huffmanBomb(1<<21), Options{})
+	if err != nil || len(out) != 1<<21 {
+		t.Fatalf("Expected %d bytes without limits, got %d and %v", 1<<21, len(out), err)
+	}
+}
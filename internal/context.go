@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"context"
+	"io"
+)
+
+// EncodeContext encodes r like e.Encode, checking ctx between reads and
+// writes of buffers. It returns ctx.Err() once ctx is done.
+func EncodeContext(ctx context.Context, e Encoder, r io.ReadSeeker, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := e.Encode(&contextReadSeeker{contextReader{ctx: ctx, r: r}, r}, &contextWriter{ctx: ctx, w: w})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// DecodeContext decodes r like d.Decode, checking ctx between reads and
+// writes of buffers. It returns ctx.Err() once ctx is done.
+func DecodeContext(ctx context.Context, d Decoder, r io.Reader, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := d.Decode(&contextReader{ctx: ctx, r: r}, &contextWriter{ctx: ctx, w: w})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// contextReadSeeker lets encoders rewind input after counting frequencies.
+type contextReadSeeker struct {
+	contextReader
+	s io.Seeker
+}
+
+func (crs *contextReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if err := crs.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return crs.s.Seek(offset, whence)
+}
+
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw *contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
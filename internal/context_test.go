@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// cancelReader cancels context after the first read.
+type cancelReader struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (cr *cancelReader) Read(p []byte) (int, error) {
+	n, err := cr.Reader.Read(p)
+	cr.cancel()
+	return n, err
+}
+
+type cancelReadSeeker struct {
+	cancelReader
+	io.Seeker
+}
+
+func TestEncodeDecodeContext(t *testing.T) {
+	input := wordsText(rand.New(rand.NewSource(1)), 1<<20)
+	for _, name := range strings.Split(AlgorithmNames(), ",") {
+		t.Run(name, func(t *testing.T) {
+			ed, _ := NewEncoderDecoder(name, Options{})
+			var encoded, decoded bytes.Buffer
+			if err := EncodeContext(context.Background(), ed, bytes.NewReader(input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if err := DecodeContext(context.Background(), ed, bytes.NewReader(encoded.Bytes()), &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			r := bytes.NewReader(input)
+			err := EncodeContext(ctx, ed, &cancelReadSeeker{cancelReader{r, cancel}, r}, io.Discard)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected cancelled encoding, got %v", err)
+			}
+
+			ctx, cancel = context.WithCancel(context.Background())
+			err = DecodeContext(ctx, ed, &cancelReader{bytes.NewReader(encoded.Bytes()), cancel}, io.Discard)
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected cancelled decoding, got %v", err)
+			}
+		})
+	}
+}
+
+func TestEncodeContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	var encoded bytes.Buffer
+	err := EncodeContext(ctx, NewBlockEncoderDecoder(Options{}), strings.NewReader("data"), &encoded)
+	if !errors.Is(err, context.DeadlineExceeded) || encoded.Len() != 0 {
+		t.Errorf("Expected nothing written and deadline error, got %v", err)
+	}
+}
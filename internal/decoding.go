@@ -3,12 +3,20 @@ package internal
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"go-compressor/pkg/bits"
 	"io"
+	"sync"
 )
 
 const BufferSize = 1 << 16
 
+var (
+	ErrTableMismatch   = errors.New("stream references another huffman table")
+	ErrCorruptedStream = errors.New("corrupted stream")
+)
+
 type Encoder interface {
 	Encode(io.ReadSeeker, io.Writer) error
 }
@@ -23,81 +31,266 @@ type EncoderDecoder interface {
 }
 
 type HuffmanEncoderDecoder struct {
+	table    *HuffmanTable
+	progress ProgressFunc
 }
 
 func NewHuffmanEncoderDecoder() EncoderDecoder {
 	return &HuffmanEncoderDecoder{}
 }
 
+// NewHuffmanEncoderDecoderWithTable returns codec that uses shared table
+// instead of building and embedding tree for every stream.
+func NewHuffmanEncoderDecoderWithTable(t *HuffmanTable) EncoderDecoder {
+	return &HuffmanEncoderDecoder{table: t}
+}
+
 func (hmed *HuffmanEncoderDecoder) Encode(r io.ReadSeeker, w io.Writer) error {
-	bw := bufio.NewWriterSize(w, BufferSize)
-	fa, err := newFrequencyArray(r)
-	if err != nil {
+	he := huffmanEncoderPool.Get().(*HuffmanEncoder)
+	defer he.release()
+	he.table, he.progress = hmed.table, hmed.progress
+	he.Reset(w)
+	return he.Encode(r)
+}
+
+var huffmanEncoderPool = sync.Pool{New: func() any { return NewHuffmanEncoder(nil) }}
+
+// huffmanScratch keeps frequencies, forest and tree rebuilt for every input.
+type huffmanScratch struct {
+	fa     frequencyArray
+	forest forest
+	tree   huffmanTree
+}
+
+// buildTree counts bytes of r and builds their tree.
+func (hs *huffmanScratch) buildTree(r io.Reader) error {
+	if err := hs.fa.count(r); err != nil {
+		return err
+	}
+	hs.forest.reset(&hs.fa)
+	hs.tree.build(&hs.forest)
+	return nil
+}
+
+// HuffmanEncoder writes huffman streams to w. Unlike HuffmanEncoderDecoder
+// it keeps buffers and tables between streams, so reusing it with Reset
+// saves allocations.
+type HuffmanEncoder struct {
+	table    *HuffmanTable
+	progress ProgressFunc
+	br       *bufio.Reader
+	bw       *bufio.Writer
+	huffmanScratch
+}
+
+func NewHuffmanEncoder(w io.Writer) *HuffmanEncoder {
+	return &HuffmanEncoder{
+		br: bufio.NewReaderSize(nil, BufferSize),
+		bw: bufio.NewWriterSize(w, BufferSize),
+	}
+}
+
+// NewHuffmanEncoderWithTable returns encoder that references shared table
+// instead of embedding tree.
+func NewHuffmanEncoderWithTable(w io.Writer, t *HuffmanTable) *HuffmanEncoder {
+	he := NewHuffmanEncoder(w)
+	he.table = t
+	return he
+}
+
+// Reset makes encoder write next streams to w.
+func (he *HuffmanEncoder) Reset(w io.Writer) {
+	he.bw.Reset(w)
+}
+
+// release drops references to streams, table and progress function, and
+// returns encoder to the pool.
+func (he *HuffmanEncoder) release() {
+	he.br.Reset(nil)
+	he.Reset(nil)
+	he.table, he.progress = nil, nil
+	huffmanEncoderPool.Put(he)
+}
+
+// Encode writes stream of r, which is read twice: to count frequencies and
+// to encode bytes.
+func (he *HuffmanEncoder) Encode(r io.ReadSeeker) error {
+	he.br.Reset(he.progress.reader(r, StageFrequencies))
+	ht := &he.tree
+	if he.table != nil {
+		if err := he.fa.count(he.br); err != nil {
+			return err
+		}
+		ht = he.table.tree
+	} else if err := he.buildTree(he.br); err != nil {
 		return err
 	}
-	f := newForest(fa)
-	ht := newHuffmanTree(f)
-	if err := ht.writeTo(bw); err != nil {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
-	if _, err = r.Seek(0, io.SeekStart); err != nil {
+	he.br.Reset(he.progress.reader(r, StageEncoding))
+
+	// incompressible data is stored as is
+	codesSize := (ht.encodedBits(&he.fa) + byteBits - 1) / byteBits
+	if treeSize(ht, he.table)+codesSize >= storedHeaderSize+he.fa.total() {
+		return writeStored(he.bw, he.br, he.fa.total())
+	}
+
+	if err := writeTreeOrReference(he.bw, ht, he.table); err != nil {
 		return err
 	}
-	br := bufio.NewReaderSize(r, BufferSize)
 
 	// write # of bytes in original file
-	if err := binary.Write(bw, binary.LittleEndian, fa.total()); err != nil {
+	if err := binary.Write(he.bw, binary.LittleEndian, he.fa.total()); err != nil {
 		return err
 	}
 
-	bitwr := bits.NewBitWriter(bw)
-	for b, err := br.ReadByte(); err == nil; b, err = br.ReadByte() {
-		if err := bitwr.WriteBits(ht.charEncoding(b)...); err != nil {
-			return err
-		}
+	bitwr := bits.NewBitWriter(he.bw)
+	if err := ht.encodeBytes(he.br, bitwr); err != nil {
+		return err
 	}
 	if err := bitwr.Flush(); err != nil {
 		return err
 	}
-	return bw.Flush()
+	return he.bw.Flush()
 }
 
-func (hmed *HuffmanEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
-	ht, err := readNewHuffmanTree(r)
-	if err != nil {
+// treeSize returns # of bytes taken by tree (or table reference) and size
+// of original file.
+func treeSize(ht *huffmanTree, table *HuffmanTable) uint64 {
+	if table != nil {
+		return tableReferenceSize + 8
+	}
+	return 2 + huffmanNodeSize*uint64(len(ht.nodes)) + 8
+}
+
+func writeStored(bw *bufio.Writer, r io.Reader, total uint64) error {
+	if err := binary.Write(bw, binary.LittleEndian, storedReference); err != nil {
 		return err
 	}
-	br := bufio.NewReaderSize(r, BufferSize)
+	if err := binary.Write(bw, binary.LittleEndian, total); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(bw, r, int64(total)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
 
-	// read original file size
-	var bytesCnt uint64
-	if err := binary.Read(br, binary.LittleEndian, &bytesCnt); err != nil {
+func writeTreeOrReference(w io.Writer, ht *huffmanTree, table *HuffmanTable) error {
+	if table == nil {
+		return ht.writeTo(w)
+	}
+	if err := binary.Write(w, binary.LittleEndian, tableReference); err != nil {
 		return err
 	}
+	return binary.Write(w, binary.LittleEndian, table.ID())
+}
 
-	bw := bufio.NewWriterSize(w, BufferSize)
-	bitr := bits.NewBitReader(br)
-	node := ht.root()
+// readTreeOrReference reads tree into scratch unless shared table is
+// referenced.
+func readTreeOrReference(r io.Reader, table *HuffmanTable, scratch *huffmanTree) (*huffmanTree, error) {
+	var tsz int16
+	if err := binary.Read(r, binary.LittleEndian, &tsz); err != nil {
+		return nil, err
+	}
+	return readSizedTreeOrReference(r, tsz, table, scratch)
+}
 
-	var writeBytes uint64
-	for writeBytes < bytesCnt {
-		if b, err := bitr.ReadBit(); err != nil {
-			return err
-		} else if !b {
-			node = ht.getNode(int(node.left))
-		} else {
-			node = ht.getNode(int(node.right))
+func readSizedTreeOrReference(r io.Reader, tsz int16, table *HuffmanTable, scratch *huffmanTree) (*huffmanTree, error) {
+	if tsz != tableReference {
+		if err := scratch.readNodes(r, tsz); err != nil {
+			return nil, err
 		}
+		return scratch, nil
+	}
+	var id uint32
+	if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+		return nil, err
+	}
+	if table == nil || table.ID() != id {
+		return nil, fmt.Errorf("%w %08x", ErrTableMismatch, id)
+	}
+	return table.tree, nil
+}
+
+func (hmed *HuffmanEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
+	hd := huffmanDecoderPool.Get().(*HuffmanDecoder)
+	defer hd.release()
+	hd.table, hd.progress = hmed.table, hmed.progress
+	hd.Reset(r)
+	return hd.Decode(w)
+}
+
+var huffmanDecoderPool = sync.Pool{New: func() any { return NewHuffmanDecoder(nil) }}
+
+// HuffmanDecoder reads huffman streams from r, keeping buffers and tree
+// between streams like HuffmanEncoder.
+type HuffmanDecoder struct {
+	table    *HuffmanTable
+	progress ProgressFunc
+	br       *bufio.Reader
+	bw       *bufio.Writer
+	tree     huffmanTree
+}
+
+func NewHuffmanDecoder(r io.Reader) *HuffmanDecoder {
+	return &HuffmanDecoder{
+		br: bufio.NewReaderSize(r, BufferSize),
+		bw: bufio.NewWriterSize(nil, BufferSize),
+	}
+}
 
-		if node.isLeaf() {
-			if _, err := bw.Write([]byte{node.char}); err != nil {
-				return err
-			}
-			node = ht.root()
-			writeBytes++
+// NewHuffmanDecoderWithTable returns decoder of streams referencing shared
+// table.
+func NewHuffmanDecoderWithTable(r io.Reader, t *HuffmanTable) *HuffmanDecoder {
+	hd := NewHuffmanDecoder(r)
+	hd.table = t
+	return hd
+}
+
+// Reset makes decoder read next streams from r, discarding buffered data.
+func (hd *HuffmanDecoder) Reset(r io.Reader) {
+	hd.br.Reset(hd.progress.reader(r, StageDecoding))
+}
+
+func (hd *HuffmanDecoder) release() {
+	// progress is cleared first, so that reader does not wrap it
+	hd.table, hd.progress = nil, nil
+	hd.Reset(nil)
+	hd.bw.Reset(nil)
+	huffmanDecoderPool.Put(hd)
+}
+
+// Decode writes data of the next stream to w.
+func (hd *HuffmanDecoder) Decode(w io.Writer) error {
+	var tsz int16
+	if err := binary.Read(hd.br, binary.LittleEndian, &tsz); err != nil {
+		return err
+	}
+	var ht *huffmanTree
+	if tsz != storedReference {
+		var err error
+		if ht, err = readSizedTreeOrReference(hd.br, tsz, hd.table, &hd.tree); err != nil {
+			return err
 		}
 	}
-	return bw.Flush()
+
+	// read original file size
+	var bytesCnt uint64
+	if err := binary.Read(hd.br, binary.LittleEndian, &bytesCnt); err != nil {
+		return err
+	}
+	if ht == nil {
+		_, err := io.CopyN(w, hd.br, int64(bytesCnt))
+		return err
+	}
+
+	hd.bw.Reset(w)
+	if err := ht.decodeBytes(bits.NewBitReader(hd.br), hd.bw, bytesCnt); err != nil {
+		return err
+	}
+	return hd.bw.Flush()
 }
 
 var _ EncoderDecoder = &HuffmanEncoderDecoder{}
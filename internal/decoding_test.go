@@ -3,7 +3,9 @@ package internal
 import (
 	"bytes"
 	"io"
+	"math/rand"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -78,3 +80,197 @@ func TestHuffmanEncodeDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestHuffmanIncompressible(t *testing.T) {
+	random := make([]byte, 1<<18)
+	rand.New(rand.NewSource(1)).Read(random)
+	dora, _ := os.ReadFile("../test/dora.jpg")
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{
+			name:  "Random",
+			input: random,
+		},
+		{
+			name:  "DoraJPG",
+			input: dora,
+		},
+		{
+			name:  "OneByte",
+			input: []byte{0},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			hed := NewHuffmanEncoderDecoder()
+			if err := hed.Encode(bytes.NewReader(tt.input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if encoded.Len() > len(tt.input)+storedHeaderSize {
+				t.Errorf("Encoded data is too large: %d bytes for %d input bytes", encoded.Len(), len(tt.input))
+			}
+			if err := hed.Decode(&encoded, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), tt.input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+}
+
+type benchmarkInput struct {
+	name string
+	data []byte
+}
+
+// benchmarkRecord is small message, for which per-call overhead dominates.
+const benchmarkRecord = `{"id":42,"name":"gopher","email":"gopher@example.com","tags":["go","compression"]}` + "\n"
+
+// benchmarkInputs returns files of test corpus, synthetic data and records.
+func benchmarkInputs(b *testing.B) []benchmarkInput {
+	var inputs []benchmarkInput
+	for _, name := range []string{"vimbook.pdf", "dora.jpg"} {
+		data, err := os.ReadFile("../test/" + name)
+		if err != nil {
+			b.Fatalf("Unexpected error: %s", err)
+		}
+		inputs = append(inputs, benchmarkInput{name: name, data: data})
+	}
+	rnd := rand.New(rand.NewSource(1))
+	random := make([]byte, 1<<20)
+	rnd.Read(random)
+	return append(inputs,
+		benchmarkInput{name: "Random", data: random},
+		benchmarkInput{name: "Text", data: wordsText(rnd, 1<<20)},
+		benchmarkInput{name: "Record", data: []byte(benchmarkRecord)},
+		benchmarkInput{name: "Records64K", data: []byte(strings.Repeat(benchmarkRecord, 1<<16/len(benchmarkRecord)))})
+}
+
+// wordsText returns at least size bytes of words picked by rnd.
+func wordsText(rnd *rand.Rand, size int) []byte {
+	words := []string{"the ", "quick ", "brown ", "fox ", "jumps ", "over ", "lazy ", "dog\n"}
+	var text bytes.Buffer
+	for text.Len() < size {
+		text.WriteString(words[rnd.Intn(len(words))])
+	}
+	return text.Bytes()
+}
+
+func BenchmarkHuffmanEncode(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			hed := NewHuffmanEncoderDecoder()
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := hed.Encode(bytes.NewReader(in.data), io.Discard); err != nil {
+					b.Fatalf("Unexpected encoding error: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkHuffmanDecode(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			hed := NewHuffmanEncoderDecoder()
+			var encoded bytes.Buffer
+			if err := hed.Encode(bytes.NewReader(in.data), &encoded); err != nil {
+				b.Fatalf("Unexpected encoding error: %s", err)
+			}
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := hed.Decode(bytes.NewReader(encoded.Bytes()), io.Discard); err != nil {
+					b.Fatalf("Unexpected decoding error: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHuffmanEncoderDecoderReset(t *testing.T) {
+	random := make([]byte, 1<<12)
+	rand.New(rand.NewSource(1)).Read(random)
+	inputs := [][]byte{
+		[]byte("abcdefghijklmnopqrstuvwxyz, the quick brown fox"),
+		[]byte("aaaaaaaaab"),
+		nil,
+		random,
+		[]byte("aaaaaaaaaa"),
+		[]byte("mississippi river"),
+	}
+	var streams [][]byte
+	he := NewHuffmanEncoder(nil)
+	for i, input := range inputs {
+		var encoded, expected bytes.Buffer
+		he.Reset(&encoded)
+		if err := he.Encode(bytes.NewReader(input)); err != nil {
+			t.Fatalf("Unexpected encoding error of %d-th input: %s", i, err)
+		}
+		_ = NewHuffmanEncoderDecoder().Encode(bytes.NewReader(input), &expected)
+		if !bytes.Equal(encoded.Bytes(), expected.Bytes()) {
+			t.Fatalf("Reused encoder output of %d-th input differs from new encoder one", i)
+		}
+		streams = append(streams, encoded.Bytes())
+	}
+
+	hd := NewHuffmanDecoder(nil)
+	for i, stream := range streams {
+		var decoded bytes.Buffer
+		hd.Reset(bytes.NewReader(stream))
+		if err := hd.Decode(&decoded); err != nil {
+			t.Fatalf("Unexpected decoding error of %d-th stream: %s", i, err)
+		}
+		if !bytes.Equal(decoded.Bytes(), inputs[i]) {
+			t.Fatalf("Initial and decoded data of %d-th stream are different", i)
+		}
+	}
+
+	// streams written one after another are decoded without Reset
+	hd.Reset(bytes.NewReader(bytes.Join(streams, nil)))
+	for i := range streams {
+		var decoded bytes.Buffer
+		if err := hd.Decode(&decoded); err != nil {
+			t.Fatalf("Unexpected decoding error of %d-th stream: %s", i, err)
+		}
+		if !bytes.Equal(decoded.Bytes(), inputs[i]) {
+			t.Fatalf("Initial and decoded data of %d-th stream are different", i)
+		}
+	}
+}
+
+func BenchmarkHuffmanEncoderReset(b *testing.B) {
+	input := []byte(`{"id":42,"name":"gopher","email":"gopher@example.com","tags":["go","compression"]}`)
+	he := NewHuffmanEncoder(io.Discard)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		he.Reset(io.Discard)
+		if err := he.Encode(bytes.NewReader(input)); err != nil {
+			b.Fatalf("Unexpected encoding error: %s", err)
+		}
+	}
+}
+
+func BenchmarkHuffmanDecoderReset(b *testing.B) {
+	input := []byte(`{"id":42,"name":"gopher","email":"gopher@example.com","tags":["go","compression"]}`)
+	var encoded bytes.Buffer
+	_ = NewHuffmanEncoder(&encoded).Encode(bytes.NewReader(input))
+	hd := NewHuffmanDecoder(nil)
+	r := bytes.NewReader(nil)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded.Bytes())
+		hd.Reset(r)
+		if err := hd.Decode(io.Discard); err != nil {
+			b.Fatalf("Unexpected decoding error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted stream header stores key derivation function, random salt and
+// scrypt parameters. Stream key is derived from passphrase by scrypt or from
+// key file by HMAC, so that every stream has its own key. Blocks are sealed
+// by AES-256-GCM with nonce made of block offset, and end marker is followed
+// by tag sealing stream length.
+const (
+	kdfKey    = 0
+	kdfScrypt = 1
+
+	encryptionSaltSize = 16
+	encryptionKeySize  = 32
+	encryptionTagSize  = 16
+	MinKeySize         = 16
+)
+
+var (
+	ErrKeyRequired    = errors.New("stream is encrypted, passphrase or key is required")
+	ErrKeyTooShort    = fmt.Errorf("key is shorter than %d bytes", MinKeySize)
+	ErrAuthentication = errors.New("wrong passphrase or key, or tampered stream")
+)
+
+type encryptionHeader struct {
+	kdf    byte
+	salt   [encryptionSaltSize]byte
+	scrypt scryptParams
+}
+
+func newEncryptionHeader(opts *Options) (*encryptionHeader, error) {
+	eh := &encryptionHeader{kdf: kdfScrypt, scrypt: defaultScryptParams}
+	if opts.scrypt != (scryptParams{}) {
+		eh.scrypt = opts.scrypt
+	}
+	if opts.Key != nil {
+		if len(opts.Key) < MinKeySize {
+			return nil, ErrKeyTooShort
+		}
+		eh.kdf = kdfKey
+	}
+	if _, err := rand.Read(eh.salt[:]); err != nil {
+		return nil, err
+	}
+	return eh, nil
+}
+
+func (eh *encryptionHeader) appendTo(dst []byte) []byte {
+	dst = append(dst, eh.kdf)
+	dst = append(dst, eh.salt[:]...)
+	if eh.kdf == kdfScrypt {
+		dst = append(dst, eh.scrypt.logN, eh.scrypt.r, eh.scrypt.p)
+	}
+	return dst
+}
+
+func readEncryptionHeader(r io.Reader) (*encryptionHeader, error) {
+	eh := &encryptionHeader{}
+	var kdf [1]byte
+	if _, err := io.ReadFull(r, kdf[:]); err != nil {
+		return nil, err
+	}
+	eh.kdf = kdf[0]
+	if _, err := io.ReadFull(r, eh.salt[:]); err != nil {
+		return nil, err
+	}
+	switch eh.kdf {
+	case kdfKey:
+	case kdfScrypt:
+		var params [3]byte
+		if _, err := io.ReadFull(r, params[:]); err != nil {
+			return nil, err
+		}
+		eh.scrypt = scryptParams{logN: params[0], r: params[1], p: params[2]}
+		if err := eh.scrypt.validate(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown key derivation %d", ErrCorruptedStream, eh.kdf)
+	}
+	return eh, nil
+}
+
+// aead derives stream key from passphrase or key of options.
+func (eh *encryptionHeader) aead(opts *Options) (cipher.AEAD, error) {
+	var key []byte
+	switch {
+	case eh.kdf == kdfKey && opts.Key != nil:
+		if len(opts.Key) < MinKeySize {
+			return nil, ErrKeyTooShort
+		}
+		mac := hmac.New(sha256.New, opts.Key)
+		mac.Write([]byte(streamMagic))
+		mac.Write(eh.salt[:])
+		key = mac.Sum(nil)
+	case eh.kdf == kdfScrypt && opts.Passphrase != nil:
+		key = scrypt(opts.Passphrase, eh.salt[:], eh.scrypt, encryptionKeySize)
+	default:
+		return nil, ErrKeyRequired
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func blockNonce(rawOffset uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.LittleEndian.PutUint64(nonce[4:], rawOffset)
+	return nonce
+}
+
+// additionalData authenticates stream header and fields of block.
+func (bc *blockCodec) additionalData(bh *blockHeader, flags byte) []byte {
+	return bh.appendFields(append([]byte{}, bc.header...), flags)
+}
+
+// seal encrypts payload in place and updates payload size of block. Payload
+// of end marker is its tag.
+func (bc *blockCodec) seal(bh *blockHeader, flags byte, payload []byte) []byte {
+	bh.payloadSize = len(payload) + bc.aead.Overhead()
+	return bc.aead.Seal(payload[:0], blockNonce(bh.rawOffset), payload, bc.additionalData(bh, flags))
+}
+
+// open decrypts payload in place.
+func (bc *blockCodec) open(bh *blockHeader, flags byte, payload []byte) ([]byte, error) {
+	payload, err := bc.aead.Open(payload[:0], blockNonce(bh.rawOffset), payload, bc.additionalData(bh, flags))
+	if err != nil {
+		return nil, fmt.Errorf("%w at offset %d", ErrAuthentication, bh.rawOffset)
+	}
+	return payload, nil
+}
+
+// setEncryption derives key of stream and keeps its header as additional
+// data of blocks.
+func (bc *blockCodec) setEncryption(h *streamHeader, opts *Options) error {
+	bc.header = h.appendTo(nil)
+	if h.flags&flagEncrypted == 0 {
+		return nil
+	}
+	var err error
+	bc.aead, err = h.encryption.aead(opts)
+	return err
+}
+
+// openEnd checks tag following end marker.
+func (bc *blockCodec) openEnd(r io.Reader, end *blockHeader, flags byte) error {
+	tag := make([]byte, bc.aead.Overhead())
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return err
+	}
+	_, err := bc.open(end, flags, tag)
+	return err
+}
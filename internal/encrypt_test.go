@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testScryptParams make tests fast, streams store parameters they used.
+var testScryptParams = scryptParams{logN: 10, r: 8, p: 1}
+
+func encryptTestStream(t *testing.T, input []byte, opts Options) []byte {
+	t.Helper()
+	opts.scrypt = testScryptParams
+	return seekTestStream(t, input, opts)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	input := []byte(strings.Repeat("secret telemetry record\n", 20000))
+	passphrase := []byte("correct horse battery staple")
+	key := []byte("0123456789abcdef0123456789abcdef")
+	for _, tt := range []struct {
+		name       string
+		encodeOpts Options
+		decodeOpts Options
+		err        error
+	}{
+		{
+			name:       "Passphrase",
+			encodeOpts: Options{Level: MinLevel, Passphrase: passphrase, Sync: true},
+			decodeOpts: Options{Passphrase: passphrase},
+		},
+		{
+			name:       "KeyNoSync",
+			encodeOpts: Options{Level: MinLevel, Key: key, Filters: []Filter{{Kind: FilterDelta}}},
+			decodeOpts: Options{Key: key},
+		},
+		{
+			name:       "WrongPassphrase",
+			encodeOpts: Options{Level: MinLevel, Passphrase: passphrase},
+			decodeOpts: Options{Passphrase: []byte("wrong")},
+			err:        ErrAuthentication,
+		},
+		{
+			name:       "NoKey",
+			encodeOpts: Options{Level: MinLevel, Key: key},
+			decodeOpts: Options{Passphrase: passphrase},
+			err:        ErrKeyRequired,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := encryptTestStream(t, input, tt.encodeOpts)
+			if bytes.Contains(encoded, []byte("telemetry")) {
+				t.Errorf("Encrypted stream contains plain text")
+			}
+			var decoded bytes.Buffer
+			err := NewBlockEncoderDecoder(tt.decodeOpts).Decode(bytes.NewReader(encoded), &decoded)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err == nil && !bytes.Equal(decoded.Bytes(), input) {
+				t.Fatalf("Decoded data differs from input")
+			}
+		})
+	}
+}
+
+func TestEncryptTampering(t *testing.T) {
+	input := []byte(strings.Repeat("secret telemetry record\n", 20000))
+	key := []byte("0123456789abcdef0123456789abcdef")
+	opts := Options{Level: MinLevel, Key: key, SeekIndex: true}
+	encoded := encryptTestStream(t, input, opts)
+	index, err := readSeekIndex(bytes.NewReader(encoded), int64(len(encoded)), newStreamHeader(&opts).flags)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	end := index.entries[len(index.entries)-1].blockOffset
+	for _, tt := range []struct {
+		name   string
+		tamper func([]byte) []byte
+	}{
+		{name: "Payload", tamper: func(s []byte) []byte {
+			s[index.entries[1].blockOffset+10] ^= 1
+			return s
+		}},
+		{name: "Header", tamper: func(s []byte) []byte {
+			s[len(streamMagic)+1] |= flagDictionary
+			return s
+		}},
+		{name: "Truncated", tamper: func(s []byte) []byte {
+			// drop the last block and move end marker
+			last := index.entries[len(index.entries)-2].blockOffset
+			return append(s[:last], s[end:]...)
+		}},
+		{name: "Reordered", tamper: func(s []byte) []byte {
+			first, second := index.entries[0].blockOffset, index.entries[1].blockOffset
+			third := index.entries[2].blockOffset
+			out := append([]byte{}, s[:first]...)
+			out = append(out, s[second:third]...)
+			out = append(out, s[first:second]...)
+			return append(out, s[third:]...)
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := tt.tamper(append([]byte{}, encoded...))
+			err := NewBlockEncoderDecoder(Options{Key: key}).Decode(bytes.NewReader(tampered), &bytes.Buffer{})
+			if err == nil {
+				t.Fatalf("Tampered stream is decoded without error")
+			}
+		})
+	}
+}
+
+func TestEncryptSeekAndRecover(t *testing.T) {
+	input := []byte(strings.Repeat("secret telemetry record\n", 20000))
+	passphrase := []byte("correct horse battery staple")
+	opts := Options{Level: MinLevel, Passphrase: passphrase, SeekIndex: true, Sync: true}
+	encoded := encryptTestStream(t, input, opts)
+
+	sr, err := NewSeekableReader(bytes.NewReader(encoded), int64(len(encoded)), Options{Passphrase: passphrase})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	p := make([]byte, 1000)
+	if _, err := sr.ReadAt(p, 300000); err != nil || !bytes.Equal(p, input[300000:301000]) {
+		t.Fatalf("Unexpected read result: %v", err)
+	}
+
+	var recovered bytes.Buffer
+	report, err := Recover(bytes.NewReader(encoded), &recovered, Options{Passphrase: passphrase})
+	if err != nil || len(report.Lost) != 0 || !bytes.Equal(recovered.Bytes(), input) {
+		t.Fatalf("Unexpected recovery result: %+v, %v", report, err)
+	}
+}
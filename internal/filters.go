@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type FilterKind byte
+
+const (
+	// FilterDelta replaces every byte with its difference from previous one.
+	FilterDelta FilterKind = iota + 1
+	// FilterStride is delta of bytes Distance apart, e.g. 4 for int32 arrays.
+	FilterStride
+	// FilterX86 turns relative addresses of x86 CALL and JMP instructions
+	// into absolute ones, so that repeated calls of a function look alike.
+	FilterX86
+)
+
+var filterNames = map[FilterKind]string{
+	FilterDelta:  "delta",
+	FilterStride: "stride",
+	FilterX86:    "x86",
+}
+
+const (
+	maxFilters = 255
+
+	x86Call      = 0xe8
+	x86Jmp       = 0xe9
+	x86InstrSize = 5
+)
+
+// Filter is reversible transform applied to blocks before compression.
+// Filters are recorded in stream header and reverted by decoder.
+type Filter struct {
+	Kind     FilterKind
+	Distance int
+}
+
+// ParseFilters parses comma separated filters, e.g. "x86,stride:4".
+func ParseFilters(spec string) ([]Filter, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var filters []Filter
+	for _, f := range strings.Split(spec, ",") {
+		name, dist, hasDist := strings.Cut(f, ":")
+		var kind FilterKind
+		for k, n := range filterNames {
+			if n == name {
+				kind = k
+			}
+		}
+		if kind == 0 {
+			return nil, fmt.Errorf("unknown filter '%s'", name)
+		}
+		filter := Filter{Kind: kind}
+		if kind == FilterStride {
+			if !hasDist {
+				return nil, fmt.Errorf("stride filter needs distance, e.g. 'stride:4'")
+			}
+			d, err := strconv.Atoi(dist)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stride distance '%s'", dist)
+			}
+			filter.Distance = d
+		} else if hasDist {
+			return nil, fmt.Errorf("filter '%s' takes no distance", name)
+		}
+		if err := filter.validate(); err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (f Filter) String() string {
+	if f.Kind == FilterStride {
+		return fmt.Sprintf("%s:%d", filterNames[f.Kind], f.Distance)
+	}
+	if n, ok := filterNames[f.Kind]; ok {
+		return n
+	}
+	return fmt.Sprintf("Filter(%d)", int(f.Kind))
+}
+
+func (f Filter) validate() error {
+	if _, ok := filterNames[f.Kind]; !ok {
+		return fmt.Errorf("unknown filter %d", f.Kind)
+	}
+	if f.Kind == FilterStride && (f.Distance < 1 || f.Distance > maxBlockSize) {
+		return fmt.Errorf("stride distance %d is out of [1, %d]", f.Distance, maxBlockSize)
+	}
+	return nil
+}
+
+func (f Filter) distance() int {
+	if f.Kind == FilterDelta {
+		return 1
+	}
+	return f.Distance
+}
+
+// apply filters block in place.
+func (f Filter) apply(block []byte) {
+	switch f.Kind {
+	case FilterDelta, FilterStride:
+		for i := len(block) - 1; i >= f.distance(); i-- {
+			block[i] -= block[i-f.distance()]
+		}
+	case FilterX86:
+		x86Convert(block, true)
+	}
+}
+
+// revert restores filtered block in place.
+func (f Filter) revert(block []byte) {
+	switch f.Kind {
+	case FilterDelta, FilterStride:
+		for i := f.distance(); i < len(block); i++ {
+			block[i] += block[i-f.distance()]
+		}
+	case FilterX86:
+		x86Convert(block, false)
+	}
+}
+
+func applyFilters(filters []Filter, block []byte) {
+	for _, f := range filters {
+		f.apply(block)
+	}
+}
+
+func revertFilters(filters []Filter, block []byte) {
+	for i := len(filters) - 1; i >= 0; i-- {
+		filters[i].revert(block)
+	}
+}
+
+// x86Convert converts operand of every CALL and JMP opcode, whether it is
+// real instruction or not, so that the same positions are converted back.
+func x86Convert(block []byte, encode bool) {
+	for i := 0; i+x86InstrSize <= len(block); {
+		if block[i] != x86Call && block[i] != x86Jmp {
+			i++
+			continue
+		}
+		operand := block[i+1 : i+x86InstrSize]
+		addr := binary.LittleEndian.Uint32(operand)
+		next := uint32(i + x86InstrSize)
+		if encode {
+			addr += next
+		} else {
+			addr -= next
+		}
+		binary.LittleEndian.PutUint32(operand, addr)
+		i += x86InstrSize
+	}
+}
+
+func appendFilters(dst []byte, filters []Filter) []byte {
+	dst = append(dst, byte(len(filters)))
+	for _, f := range filters {
+		dst = append(dst, byte(f.Kind))
+		dst = binary.AppendUvarint(dst, uint64(f.Distance))
+	}
+	return dst
+}
+
+func readFilters(r io.ByteReader) ([]Filter, error) {
+	cnt, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	filters := make([]Filter, cnt)
+	for i := range filters {
+		kind, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		dist, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = Filter{Kind: FilterKind(kind), Distance: int(min(dist, maxBlockSize+1))}
+		if err := filters[i].validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrCorruptedStream, err)
+		}
+	}
+	return filters, nil
+}
+
+// filteredCodec applies filters to input of raw codec by maxBlockSize chunks
+// and records them in front of codec stream, so that decoder reverts them.
+type filteredCodec struct {
+	EncoderDecoder
+	filters []Filter
+}
+
+func (fc *filteredCodec) Encode(r io.ReadSeeker, w io.Writer) error {
+	if _, err := w.Write(appendFilters(nil, fc.filters)); err != nil {
+		return err
+	}
+	return fc.EncoderDecoder.Encode(&filterReader{r: r, filters: fc.filters}, w)
+}
+
+// Decode reverts filters recorded in stream, whatever filters codec has.
+func (fc *filteredCodec) Decode(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(r, BufferSize)
+	filters, err := readFilters(br)
+	if err != nil {
+		return err
+	}
+	fw := &filterWriter{w: w, filters: filters}
+	if err := fc.EncoderDecoder.Decode(br, fw); err != nil {
+		return err
+	}
+	return fw.flush()
+}
+
+// filterReader reads filtered chunks of r, which are reloaded on seeks.
+type filterReader struct {
+	r        io.ReadSeeker
+	filters  []Filter
+	chunk    []byte
+	chunkPos int64
+	pos      int64
+}
+
+func (fr *filterReader) Read(p []byte) (int, error) {
+	if fr.chunk == nil || fr.pos < fr.chunkPos || fr.pos >= fr.chunkPos+int64(len(fr.chunk)) {
+		if fr.chunk != nil && len(fr.chunk) < maxBlockSize && fr.pos >= fr.chunkPos {
+			// the last chunk is read
+			return 0, io.EOF
+		}
+		if err := fr.load(fr.pos - fr.pos%maxBlockSize); err != nil {
+			return 0, err
+		}
+		if fr.pos >= fr.chunkPos+int64(len(fr.chunk)) {
+			return 0, io.EOF
+		}
+	}
+	n := copy(p, fr.chunk[fr.pos-fr.chunkPos:])
+	fr.pos += int64(n)
+	return n, nil
+}
+
+func (fr *filterReader) load(start int64) error {
+	if _, err := fr.r.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if cap(fr.chunk) < maxBlockSize {
+		fr.chunk = make([]byte, maxBlockSize)
+	}
+	n, err := io.ReadFull(fr.r, fr.chunk[:maxBlockSize])
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		fr.chunk = nil
+		return err
+	}
+	fr.chunk, fr.chunkPos = fr.chunk[:n], start
+	applyFilters(fr.filters, fr.chunk)
+	return nil
+}
+
+// Seek sets position of the next Read, filters keep size of input.
+func (fr *filterReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += fr.pos
+	case io.SeekEnd:
+		size, err := fr.r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		offset += size
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	fr.pos = offset
+	return offset, nil
+}
+
+// filterWriter reverts filters of maxBlockSize chunks before writing them.
+type filterWriter struct {
+	w       io.Writer
+	filters []Filter
+	chunk   []byte
+}
+
+func (fw *filterWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), maxBlockSize-len(fw.chunk))
+		fw.chunk, p = append(fw.chunk, p[:n]...), p[n:]
+		if len(fw.chunk) == maxBlockSize {
+			if err := fw.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush writes the last chunk, which may be shorter than maxBlockSize.
+func (fw *filterWriter) flush() error {
+	revertFilters(fw.filters, fw.chunk)
+	_, err := fw.w.Write(fw.chunk)
+	fw.chunk = fw.chunk[:0]
+	return err
+}
@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestParseFilters(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		spec     string
+		expected []Filter
+		invalid  bool
+	}{
+		{
+			name: "Empty",
+			spec: "",
+		},
+		{
+			name:     "Chain",
+			spec:     "x86,stride:4,delta",
+			expected: []Filter{{Kind: FilterX86}, {Kind: FilterStride, Distance: 4}, {Kind: FilterDelta}},
+		},
+		{
+			name:    "UnknownFilter",
+			spec:    "arm",
+			invalid: true,
+		},
+		{
+			name:    "StrideWithoutDistance",
+			spec:    "stride",
+			invalid: true,
+		},
+		{
+			name:    "ZeroStride",
+			spec:    "stride:0",
+			invalid: true,
+		},
+		{
+			name:    "DeltaWithDistance",
+			spec:    "delta:2",
+			invalid: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			filters, err := ParseFilters(tt.spec)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("Invalid filters are parsed without error: %v", filters)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if !slices.Equal(filters, tt.expected) {
+				t.Errorf("Parsed filters differ: expected %v, got %v", tt.expected, filters)
+			}
+		})
+	}
+}
+
+func TestFilterApplyRevert(t *testing.T) {
+	random := make([]byte, 1<<16)
+	rand.New(rand.NewSource(5)).Read(random)
+	code := bytes.Repeat([]byte{0x55, x86Call, 0x10, 0x00, 0x00, 0x00, x86Jmp, 0xf0, 0xff, 0xff, 0xff, 0xc3}, 100)
+	for _, f := range []Filter{{Kind: FilterDelta}, {Kind: FilterStride, Distance: 4}, {Kind: FilterX86}} {
+		for _, input := range [][]byte{{}, {1, 2, 3}, random, code} {
+			block := append([]byte(nil), input...)
+			f.apply(block)
+			f.revert(block)
+			if !bytes.Equal(block, input) {
+				t.Errorf("Filter %s is not reverted for %d bytes", f, len(input))
+			}
+		}
+	}
+}
+
+func TestX86FilterMakesCallsAlike(t *testing.T) {
+	// calls of the same function from different places
+	var code []byte
+	for i := 0; i < 4; i++ {
+		code = append(code, 0x90, x86Call)
+		code = binary.LittleEndian.AppendUint32(code, uint32(1000-len(code)-4))
+	}
+	f := Filter{Kind: FilterX86}
+	f.apply(code)
+	for i := 1; i < 4; i++ {
+		if !bytes.Equal(code[2:6], code[i*6+2:i*6+6]) {
+			t.Errorf("Call %d has different absolute address: %v and %v", i, code[2:6], code[i*6+2:i*6+6])
+		}
+	}
+}
+
+func TestStrideFilterOnTelemetry(t *testing.T) {
+	// slowly changing little endian float32 column
+	var telemetry []byte
+	for i := 0; i < 1<<15; i++ {
+		v := float32(20 + 5*math.Sin(float64(i)/500))
+		telemetry = binary.LittleEndian.AppendUint32(telemetry, math.Float32bits(v))
+	}
+	var plain, filtered, decoded bytes.Buffer
+	if err := NewBlockEncoderDecoder(Options{}).Encode(bytes.NewReader(telemetry), &plain); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	opts := Options{Filters: []Filter{{Kind: FilterStride, Distance: 4}}}
+	if err := NewBlockEncoderDecoder(opts).Encode(bytes.NewReader(telemetry), &filtered); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if filtered.Len() >= plain.Len() {
+		t.Errorf("Stride filter does not help: %d bytes with it, %d without", filtered.Len(), plain.Len())
+	}
+	// decoder takes filters from the stream
+	if err := NewBlockEncoderDecoder(Options{}).Decode(&filtered, &decoded); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), telemetry) {
+		t.Fatalf("Initial and decoded data are different")
+	}
+}
+
+func TestFilteredRawCodecs(t *testing.T) {
+	// int32 counters spanning a few filter chunks
+	var input []byte
+	for i := 0; len(input) < 2*maxBlockSize+100; i++ {
+		input = binary.LittleEndian.AppendUint32(input, uint32(i*7))
+	}
+	filters := []Filter{{Kind: FilterStride, Distance: 4}, {Kind: FilterDelta}}
+	for _, algorithm := range []string{"huffman", "lz", "lzw", "rle"} {
+		t.Run(algorithm, func(t *testing.T) {
+			var plain, filtered, decoded bytes.Buffer
+			raw, _ := NewEncoderDecoder(algorithm, Options{})
+			if err := raw.Encode(bytes.NewReader(input), &plain); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			ed, _ := NewEncoderDecoder(algorithm, Options{Filters: filters})
+			if err := ed.Encode(bytes.NewReader(input), &filtered); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if filtered.Len() >= plain.Len() {
+				t.Errorf("Filters do not help: %d bytes with them, %d without", filtered.Len(), plain.Len())
+			}
+			// decoder takes filters from the stream
+			ed, _ = NewEncoderDecoder(algorithm, Options{Filters: []Filter{{Kind: FilterX86}}})
+			if err := ed.Decode(&filtered, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+}
@@ -20,16 +20,28 @@ type frequencyArray struct {
 
 func newFrequencyArray(r io.Reader) (frequencyCounter, error) {
 	fa := &frequencyArray{}
-	br := bufio.NewReader(r)
+	if err := fa.count(r); err != nil {
+		return nil, err
+	}
+	return fa, nil
+}
+
+// count replaces frequencies with ones of bytes of r.
+func (fa *frequencyArray) count(r io.Reader) error {
+	*fa = frequencyArray{}
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
 	b, err := br.ReadByte()
 	for ; err == nil; b, err = br.ReadByte() {
 		fa.byteFrequency[b]++
 		fa.totalCount++
 	}
 	if !errors.Is(err, io.EOF) {
-		return nil, err
+		return err
 	}
-	return fa, nil
+	return nil
 }
 
 func (fa *frequencyArray) frequencyOf(b byte) uint64 {
@@ -53,9 +65,17 @@ type forest struct {
 }
 
 func newForest(fc frequencyCounter) *forest {
-	f := &forest{
-		trees: make([]forestTree, 0, bytesCount),
+	f := &forest{}
+	f.reset(fc)
+	return f
+}
+
+// reset plants tree for every counted byte, reusing trees slice.
+func (f *forest) reset(fc frequencyCounter) {
+	if f.trees == nil {
+		f.trees = make([]forestTree, 0, bytesCount)
 	}
+	f.trees = f.trees[:0]
 	for b := 0; b < bytesCount; b++ {
 		if fc.frequencyOf(byte(b)) > 0 {
 			f.trees = append(f.trees, forestTree{
@@ -65,7 +85,6 @@ func newForest(fc frequencyCounter) *forest {
 			})
 		}
 	}
-	return f
 }
 
 func (f *forest) findTwoWithMinFrequency() (*forestTree, *forestTree) {
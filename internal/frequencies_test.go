@@ -228,3 +228,17 @@ func TestForest_FindTwoWithMinFrequency(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkNewFrequencyArray(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := newFrequencyArray(bytes.NewReader(in.data)); err != nil {
+					b.Fatalf("Unexpected error: %s", err)
+				}
+			}
+		})
+	}
+}
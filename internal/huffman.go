@@ -2,9 +2,27 @@ package internal
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-compressor/pkg/bits"
 	"io"
 )
 
+// tableReference is written instead of tree size when stream is encoded
+// with shared HuffmanTable, and is followed by table ID. storedReference
+// is written when original bytes are stored without coding.
+const (
+	tableReference  int16 = -1
+	storedReference int16 = -2
+
+	tableReferenceSize = 2 + 4
+	storedHeaderSize   = 2 + 8
+	huffmanNodeSize    = 2 + 2 + 2 + 1
+	byteBits           = 8
+)
+
+var ErrCorruptedTree = errors.New("corrupted huffman tree")
+
 type huffmanNode struct {
 	left   int16
 	right  int16
@@ -52,6 +70,10 @@ func (hn *huffmanNode) writeTo(w io.Writer) error {
 type huffmanTree struct {
 	nodes         []huffmanNode
 	nodeEncodings [bytesCount][]bool
+	// codes backs nodeEncodings, so that they are reused with the tree.
+	codes []bool
+	// referenced marks children of read nodes.
+	referenced []bool
 }
 
 func (ht *huffmanTree) getNode(idx int) *huffmanNode {
@@ -63,13 +85,19 @@ func (ht *huffmanTree) root() *huffmanNode {
 }
 
 func (ht *huffmanTree) buildEncodings() {
+	ht.nodeEncodings = [bytesCount][]bool{}
+	ht.codes = ht.codes[:0]
+	if len(ht.nodes) == 0 {
+		return
+	}
 	ht.encodingDfs(ht.root(), nil)
 }
 
 func (ht *huffmanTree) encodingDfs(node *huffmanNode, encoding []bool) {
 	if node.left == node.right && node.left == -1 {
-		ht.nodeEncodings[node.char] = make([]bool, len(encoding))
-		copy(ht.nodeEncodings[node.char], encoding)
+		start := len(ht.codes)
+		ht.codes = append(ht.codes, encoding...)
+		ht.nodeEncodings[node.char] = ht.codes[start:len(ht.codes):len(ht.codes)]
 		return
 	}
 	if node.left != -1 {
@@ -85,7 +113,18 @@ func (ht *huffmanTree) encodingDfs(node *huffmanNode, encoding []bool) {
 }
 
 func newHuffmanTree(f *forest) *huffmanTree {
-	ht := &huffmanTree{nodes: make([]huffmanNode, 0, f.size())}
+	ht := &huffmanTree{}
+	ht.build(f)
+	return ht
+}
+
+// build replaces tree with one merged from trees of forest, reusing nodes
+// and codes of the previous one.
+func (ht *huffmanTree) build(f *forest) {
+	if cap(ht.nodes) < 2*f.size() {
+		ht.nodes = make([]huffmanNode, 0, 2*f.size())
+	}
+	ht.nodes = ht.nodes[:0]
 	for _, t := range f.trees {
 		ht.nodes = append(ht.nodes, huffmanNode{
 			left:   -1,
@@ -114,13 +153,74 @@ func newHuffmanTree(f *forest) *huffmanTree {
 	}
 
 	ht.buildEncodings()
-	return ht
 }
 
 func (ht *huffmanTree) charEncoding(char byte) []bool {
 	return ht.nodeEncodings[char]
 }
 
+func (ht *huffmanTree) encodeBytes(r io.ByteReader, bw bits.BitWriter) error {
+	b, err := r.ReadByte()
+	for ; err == nil; b, err = r.ReadByte() {
+		if err := bw.WriteBits(ht.charEncoding(b)...); err != nil {
+			return err
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// decodeBytes writes n decoded bytes to w. Tree of single leaf has empty
+// code, so no bits are read for it.
+func (ht *huffmanTree) decodeBytes(br bits.BitReader, w io.ByteWriter, n uint64) error {
+	if n == 0 {
+		return nil
+	}
+	if len(ht.nodes) == 0 {
+		return ErrCorruptedTree
+	}
+	root := ht.root()
+	if root.isLeaf() {
+		for ; n > 0; n-- {
+			if err := w.WriteByte(root.char); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	node := root
+	for n > 0 {
+		if b, err := br.ReadBit(); err != nil {
+			return err
+		} else if !b {
+			node = ht.getNode(int(node.left))
+		} else {
+			node = ht.getNode(int(node.right))
+		}
+
+		if node.isLeaf() {
+			if err := w.WriteByte(node.char); err != nil {
+				return err
+			}
+			node = root
+			n--
+		}
+	}
+	return nil
+}
+
+// encodedBits returns # of bits taken by codes of all counted bytes.
+func (ht *huffmanTree) encodedBits(fc frequencyCounter) uint64 {
+	var sum uint64
+	for b := 0; b < bytesCount; b++ {
+		sum += fc.frequencyOf(byte(b)) * uint64(len(ht.charEncoding(byte(b))))
+	}
+	return sum
+}
+
 func (ht *huffmanTree) writeTo(w io.Writer) error {
 	tsz := int16(len(ht.nodes))
 	if err := binary.Write(w, binary.LittleEndian, tsz); err != nil {
@@ -139,14 +239,56 @@ func readNewHuffmanTree(r io.Reader) (*huffmanTree, error) {
 	if err := binary.Read(r, binary.LittleEndian, &tsz); err != nil {
 		return nil, err
 	}
-	ht := &huffmanTree{nodes: make([]huffmanNode, tsz)}
+	return readHuffmanTreeNodes(r, tsz)
+}
+
+func readHuffmanTreeNodes(r io.Reader, tsz int16) (*huffmanTree, error) {
+	ht := &huffmanTree{}
+	if err := ht.readNodes(r, tsz); err != nil {
+		return nil, err
+	}
+	return ht, nil
+}
+
+// readNodes replaces tree with tsz nodes read from r.
+func (ht *huffmanTree) readNodes(r io.Reader, tsz int16) error {
+	if tsz < 0 {
+		return fmt.Errorf("%w: tree size %d", ErrCorruptedTree, tsz)
+	}
+	if cap(ht.nodes) < int(tsz) {
+		ht.nodes = make([]huffmanNode, tsz)
+	}
+	ht.nodes = ht.nodes[:tsz]
+	if cap(ht.referenced) < int(tsz) {
+		ht.referenced = make([]bool, tsz)
+	}
+	referenced := ht.referenced[:tsz]
+	clear(referenced)
 	for i := int16(0); i < tsz; i++ {
 		if nodePtr, err := readNewHuffmanNode(r); err == nil {
 			ht.nodes[i] = *nodePtr
 		} else {
-			return nil, err
+			return err
+		}
+		if err := checkNodeChildren(&ht.nodes[i], i, referenced); err != nil {
+			return err
 		}
 	}
 	ht.buildEncodings()
-	return ht, nil
+	return nil
+}
+
+// checkNodeChildren makes sure that children of i-th node precede it and
+// have no other parent, so that tree is walked in linear time.
+func checkNodeChildren(node *huffmanNode, i int16, referenced []bool) error {
+	if node.isLeaf() {
+		return nil
+	}
+	for _, child := range []int16{node.left, node.right} {
+		if child < 0 || child >= i || referenced[child] {
+			return fmt.Errorf("%w: bad child %d of node %d", ErrCorruptedTree, child, i)
+		}
+		referenced[child] = true
+	}
+	return nil
 }
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"os"
 	"slices"
@@ -193,3 +195,54 @@ func TestHuffmanTreeWriteRead(t *testing.T) {
 		})
 	}
 }
+
+func TestReadHuffmanTreeBadChildren(t *testing.T) {
+	leaf := huffmanNode{left: -1, right: -1, parent: -1}
+	for _, tt := range []struct {
+		name  string
+		nodes []huffmanNode
+	}{
+		{
+			name:  "OutOfRange",
+			nodes: []huffmanNode{leaf, leaf, {left: 0, right: 7, parent: -1}},
+		},
+		{
+			name:  "SelfReference",
+			nodes: []huffmanNode{leaf, {left: 0, right: 1, parent: -1}},
+		},
+		{
+			name:  "MissingChild",
+			nodes: []huffmanNode{leaf, {left: 0, right: -1, parent: -1}},
+		},
+		{
+			name:  "SharedChild",
+			nodes: []huffmanNode{leaf, {left: 0, right: 0, parent: -1}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := (&huffmanTree{nodes: tt.nodes}).writeTo(&buf); err != nil {
+				t.Fatalf("Unexpected error during HT writing: %s", err)
+			}
+			if _, err := readNewHuffmanTree(&buf); !errors.Is(err, ErrCorruptedTree) {
+				t.Errorf("Expected corrupted tree error, got %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkNewHuffmanTree(b *testing.B) {
+	for _, in := range benchmarkInputs(b) {
+		b.Run(in.name, func(b *testing.B) {
+			fa, err := newFrequencyArray(bytes.NewReader(in.data))
+			if err != nil {
+				b.Fatalf("Unexpected error: %s", err)
+			}
+			b.SetBytes(int64(len(in.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				newHuffmanTree(newForest(fa))
+			}
+		})
+	}
+}
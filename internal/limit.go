@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+)
+
+// LimitError is returned by decoders when output exceeds MaxOutputSize or
+// MaxRatio of options.
+type LimitError struct {
+	// Output and Input are # of bytes decoded and read when limit was hit.
+	Output, Input int64
+	MaxOutputSize int64
+	MaxRatio      float64
+}
+
+func (e *LimitError) Error() string {
+	if e.MaxOutputSize > 0 && e.Output > e.MaxOutputSize {
+		return fmt.Sprintf("decoded output exceeds %d bytes", e.MaxOutputSize)
+	}
+	return fmt.Sprintf("decoded output exceeds %.0f times %d input bytes", e.MaxRatio, e.Input)
+}
+
+func (o *Options) limited() bool {
+	return o.MaxOutputSize > 0 || o.MaxRatio > 0
+}
+
+// limitDecoding returns r counting input and w failing with LimitError
+// before limits of options are exceeded.
+func (o *Options) limitDecoding(r io.Reader, w io.Writer) (io.Reader, io.Writer) {
+	if !o.limited() {
+		return r, w
+	}
+	cr := &countingReader{r: r}
+	return cr, &limitWriter{w: w, in: cr, maxSize: o.MaxOutputSize, maxRatio: o.MaxRatio}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+type limitWriter struct {
+	w        io.Writer
+	in       *countingReader
+	n        int64
+	maxSize  int64
+	maxRatio float64
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	out := lw.n + int64(len(p))
+	if lw.maxSize > 0 && out > lw.maxSize ||
+		lw.maxRatio > 0 && float64(out) > lw.maxRatio*float64(max(lw.in.n, 1)) {
+		return 0, &LimitError{Output: out, Input: lw.in.n, MaxOutputSize: lw.maxSize, MaxRatio: lw.maxRatio}
+	}
+	n, err := lw.w.Write(p)
+	lw.n += int64(n)
+	return n, err
+}
+
+// limitedDecoder applies limits of options to raw codecs.
+type limitedDecoder struct {
+	EncoderDecoder
+	opts Options
+}
+
+func (ld *limitedDecoder) Decode(r io.Reader, w io.Writer) error {
+	r, w = ld.opts.limitDecoding(r, w)
+	return ld.EncoderDecoder.Decode(r, w)
+}
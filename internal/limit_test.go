@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+// huffmanBomb is stream of single leaf tree, which expands to n bytes without
+// reading any codes.
+func huffmanBomb(n uint64) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, int16(1))
+	_ = (&huffmanNode{left: -1, right: -1, parent: -1, char: 'a'}).writeTo(&buf)
+	_ = binary.Write(&buf, binary.LittleEndian, n)
+	return buf.Bytes()
+}
+
+func TestDecodeMaxOutputSize(t *testing.T) {
+	for _, algorithm := range []string{"huffman", DefaultAlgorithm} {
+		t.Run(algorithm, func(t *testing.T) {
+			ed, _ := NewEncoderDecoder(algorithm, Options{MaxOutputSize: 1 << 20})
+			var le *LimitError
+			err := ed.Decode(bytes.NewReader(huffmanBomb(1<<40)), io.Discard)
+			if !errors.As(err, &le) || le.Output > 1<<20+BufferSize {
+				t.Fatalf("Expected limit error, got %v", err)
+			}
+
+			var decoded bytes.Buffer
+			if err := ed.Decode(bytes.NewReader(huffmanBomb(1<<20)), &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if decoded.Len() != 1<<20 {
+				t.Errorf("Expected %d decoded bytes, got %d", 1<<20, decoded.Len())
+			}
+		})
+	}
+}
+
+func TestDecodeMaxRatio(t *testing.T) {
+	input := make([]byte, 1<<20)
+	var encoded bytes.Buffer
+	if err := NewBlockEncoderDecoder(Options{}).Encode(bytes.NewReader(input), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	ratio := float64(len(input)) / float64(encoded.Len())
+
+	err := NewBlockEncoderDecoder(Options{MaxRatio: ratio / 2}).Decode(&encoded, io.Discard)
+	var le *LimitError
+	if !errors.As(err, &le) || le.MaxRatio != ratio/2 {
+		t.Fatalf("Expected limit error, got %v", err)
+	}
+	encoded.Reset()
+	_ = NewBlockEncoderDecoder(Options{}).Encode(bytes.NewReader(input), &encoded)
+	if err := NewBlockEncoderDecoder(Options{MaxRatio: ratio * 2}).Decode(&encoded, io.Discard); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+}
+
+func TestDecodeRLEBlockBomb(t *testing.T) {
+	// stored RLE block of longest runs claims to be 1000 bytes long
+	payload := append([]byte{0}, bytes.Repeat([]byte{0, 255, 'a'}, maxStageSize/3-1)...)
+	bh := &blockHeader{method: transformRLE | entropyStored, rawSize: 1000, payloadSize: len(payload)}
+	stream := append(bh.appendFields((&streamHeader{}).appendTo(nil), 0), payload...)
+	stream = append(stream, blockEnd)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := NewBlockEncoderDecoder(Options{MaxOutputSize: 1 << 20}).Decode(bytes.NewReader(stream), io.Discard)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrCorruptedStream) {
+		t.Fatalf("Expected %v, got %v", ErrCorruptedStream, err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4*maxStageSize {
+		t.Errorf("Block is expanded in memory: %d bytes allocated", allocated)
+	}
+}
@@ -0,0 +1,287 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"sync"
+)
+
+// LZ token stream consists of groups of up to 8 items preceded by flags byte,
+// whose i-th bit tells whether i-th item is literal byte (0) or match (1).
+// Match is 16-bit little endian distance followed by length minus lzMinMatch.
+const (
+	lzWindowSize    = 1<<16 - 1
+	lzMinMatch      = 3
+	lzMaxMatch      = lzMinMatch + 255
+	lzHashBits      = 15
+	lzGroupSize     = 8
+	lzDefaultChain  = 64
+	lzHistoryLimit  = 4 * lzWindowSize
+	lzChunkSize     = 1 << 20
+	lzFlagHasPreset = 1 << 0
+)
+
+var ErrDictionaryMismatch = errors.New("stream is compressed with another dictionary")
+
+func lzHash(b []byte) uint32 {
+	return (uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])) * 2654435761 >> (32 - lzHashBits)
+}
+
+// dictionaryID identifies preset dictionary in compressed stream.
+func dictionaryID(dict []byte) uint32 {
+	return adler32.Checksum(dict)
+}
+
+func lzWindow(dict []byte) []byte {
+	if len(dict) > lzWindowSize {
+		return dict[len(dict)-lzWindowSize:]
+	}
+	return dict
+}
+
+// lzTables are hash chains of lzCompress reused between calls.
+type lzTables struct {
+	head [1 << lzHashBits]int32
+	prev []int32
+	data []byte
+}
+
+var lzTablesPool = sync.Pool{New: func() any { return new(lzTables) }}
+
+// lzCompress appends tokens of src to dst. Matches may reference dict,
+// as if it preceded src. maxChain limits candidates checked per position.
+func lzCompress(dst, src, dict []byte, maxChain int) []byte {
+	dst, _, _ = lzCompressItems(dst, 0, 0, src, dict, maxChain)
+	return dst
+}
+
+// lzCompressItems is lzCompress continuing group of items, whose flags byte
+// is dst[flagsPos], when items is not multiple of lzGroupSize. It returns
+// position of flags byte of the last group and # of items.
+func lzCompressItems(dst []byte, flagsPos, items int, src, dict []byte, maxChain int) ([]byte, int, int) {
+	t := lzTablesPool.Get().(*lzTables)
+	defer lzTablesPool.Put(t)
+	dict = lzWindow(dict)
+	t.data = append(append(t.data[:0], dict...), src...)
+	data := t.data
+
+	// positions are stored incremented, so zero means no position
+	head := &t.head
+	clear(head[:])
+	if cap(t.prev) < len(data) {
+		t.prev = make([]int32, len(data))
+	}
+	// prev of position is set on its insertion, so it is not cleared
+	prev := t.prev[:len(data)]
+	insert := func(i int) {
+		if i+lzMinMatch <= len(data) {
+			h := lzHash(data[i:])
+			prev[i] = head[h]
+			head[h] = int32(i + 1)
+		}
+	}
+	for i := range dict {
+		insert(i)
+	}
+
+	for i := len(dict); i < len(data); items++ {
+		if items%lzGroupSize == 0 {
+			flagsPos = len(dst)
+			dst = append(dst, 0)
+		}
+		bestLen, bestDist := 0, 0
+		if i+lzMinMatch <= len(data) {
+			limit := min(lzMaxMatch, len(data)-i)
+			for j, chain := int(head[lzHash(data[i:])])-1, maxChain; j >= 0 && chain > 0 && i-j <= lzWindowSize; j, chain = int(prev[j])-1, chain-1 {
+				l := 0
+				for l < limit && data[j+l] == data[i+l] {
+					l++
+				}
+				if l > bestLen {
+					bestLen, bestDist = l, i-j
+					if l == limit {
+						break
+					}
+				}
+			}
+		}
+		if bestLen < lzMinMatch {
+			dst = append(dst, data[i])
+			insert(i)
+			i++
+			continue
+		}
+		dst[flagsPos] |= 1 << (items % lzGroupSize)
+		dst = append(dst, byte(bestDist), byte(bestDist>>8), byte(bestLen-lzMinMatch))
+		for end := i + bestLen; i < end; i++ {
+			insert(i)
+		}
+	}
+	return dst, flagsPos, items
+}
+
+// lzDecompress reads tokens from r until size bytes are written to w.
+func lzDecompress(r io.ByteReader, w io.Writer, dict []byte, size uint64) error {
+	dict = lzWindow(dict)
+	hist := make([]byte, 0, lzHistoryLimit+lzMaxMatch)
+	hist = append(hist, dict...)
+	flushed := len(hist)
+
+	var written uint64
+	var flags byte
+	for items := 0; written < size; items++ {
+		if items%lzGroupSize == 0 {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			flags = b
+		}
+		if flags&(1<<(items%lzGroupSize)) == 0 {
+			b, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			hist = append(hist, b)
+			written++
+		} else {
+			var match [3]byte
+			for i := range match {
+				b, err := r.ReadByte()
+				if err != nil {
+					return err
+				}
+				match[i] = b
+			}
+			dist := int(binary.LittleEndian.Uint16(match[:]))
+			length := int(match[2]) + lzMinMatch
+			if dist == 0 || dist > len(hist) || uint64(length) > size-written {
+				return fmt.Errorf("%w: invalid match", ErrCorruptedStream)
+			}
+			for start := len(hist) - dist; length > 0; length-- {
+				hist = append(hist, hist[start])
+				start++
+			}
+			written += uint64(match[2]) + lzMinMatch
+		}
+
+		if len(hist) >= lzHistoryLimit {
+			if _, err := w.Write(hist[flushed:]); err != nil {
+				return err
+			}
+			hist = hist[:copy(hist, hist[len(hist)-lzWindowSize:])]
+			flushed = len(hist)
+		}
+	}
+	_, err := w.Write(hist[flushed:])
+	return err
+}
+
+type LZEncoderDecoder struct {
+	dict     []byte
+	progress ProgressFunc
+}
+
+// NewLZEncoderDecoder returns LZ codec whose match window is primed with
+// preset dictionary. Dictionary may be nil.
+func NewLZEncoderDecoder(dict []byte) EncoderDecoder {
+	return &LZEncoderDecoder{dict: dict}
+}
+
+// Encode compresses input by lzChunkSize chunks, priming window of every
+// chunk with the end of the previous one, so that memory does not grow with
+// input size.
+func (lzed *LZEncoderDecoder) Encode(r io.ReadSeeker, w io.Writer) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, BufferSize)
+	if lzed.dict == nil {
+		err = bw.WriteByte(0)
+	} else if err = bw.WriteByte(lzFlagHasPreset); err == nil {
+		err = binary.Write(bw, binary.LittleEndian, dictionaryID(lzed.dict))
+	}
+	if err != nil {
+		return err
+	}
+
+	// write # of bytes in original file
+	if err := binary.Write(bw, binary.LittleEndian, uint64(size)); err != nil {
+		return err
+	}
+	pr := io.LimitReader(lzed.progress.reader(r, StageEncoding), size)
+	chunk := make([]byte, lzChunkSize)
+	window := append([]byte{}, lzWindow(lzed.dict)...)
+	var tokens []byte
+	var flagsPos, items int
+	for read := int64(0); read < size; {
+		n, err := io.ReadFull(pr, chunk)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if n == 0 {
+			// input is shorter than its size
+			return io.ErrUnexpectedEOF
+		}
+		read += int64(n)
+		tokens, flagsPos, items = lzCompressItems(tokens, flagsPos, items, chunk[:n], window, lzDefaultChain)
+		// unfinished group is kept till the next chunk
+		done := len(tokens)
+		if items%lzGroupSize != 0 {
+			done = flagsPos
+		}
+		if _, err := bw.Write(tokens[:done]); err != nil {
+			return err
+		}
+		tokens = tokens[:copy(tokens, tokens[done:])]
+		flagsPos = 0
+		window = append(window, chunk[:n]...)
+		if len(window) > lzWindowSize {
+			window = window[:copy(window, window[len(window)-lzWindowSize:])]
+		}
+	}
+	if _, err := bw.Write(tokens); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (lzed *LZEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(lzed.progress.reader(r, StageDecoding), BufferSize)
+	flags, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	var dict []byte
+	if flags&lzFlagHasPreset != 0 {
+		var id uint32
+		if err := binary.Read(br, binary.LittleEndian, &id); err != nil {
+			return err
+		}
+		if lzed.dict == nil || dictionaryID(lzed.dict) != id {
+			return fmt.Errorf("%w %08x", ErrDictionaryMismatch, id)
+		}
+		dict = lzed.dict
+	}
+
+	// read original file size
+	var bytesCnt uint64
+	if err := binary.Read(br, binary.LittleEndian, &bytesCnt); err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, BufferSize)
+	if err := lzDecompress(br, bw, dict, bytesCnt); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+var _ EncoderDecoder = &LZEncoderDecoder{}
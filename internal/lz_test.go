@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+var lzDictionary = []byte(`{"timestamp":"2024-07-28T00:00:00Z","level":"info",` +
+	`"url":"https://example.com/api/v1/users","message":""}`)
+
+func TestLZEncodeDecode(t *testing.T) {
+	vimbook, _ := os.ReadFile("../test/vimbook.pdf")
+	for _, tt := range []struct {
+		name  string
+		input []byte
+		dict  []byte
+	}{
+		{
+			name:  "Empty",
+			input: []byte{},
+		},
+		{
+			name:  "OneByte",
+			input: []byte("a"),
+		},
+		{
+			name:  "LongRun",
+			input: bytes.Repeat([]byte{0}, 100000),
+		},
+		{
+			name:  "RepeatedPattern",
+			input: []byte(strings.Repeat("abcabcabd", 1000)),
+		},
+		{
+			name:  "SmallRecordWithDictionary",
+			input: []byte(`{"timestamp":"2024-07-29T10:00:00Z","level":"info","url":"https://example.com/api/v1/users"}`),
+			dict:  lzDictionary,
+		},
+		{
+			name:  "LongDictionary",
+			input: []byte("tail of dictionary"),
+			dict:  append(bytes.Repeat([]byte{'x'}, 2*lzWindowSize), "tail of dictionary"...),
+		},
+		{
+			name:  "VimBookPDF",
+			input: vimbook,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			lzed := NewLZEncoderDecoder(tt.dict)
+			if err := lzed.Encode(bytes.NewReader(tt.input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if err := lzed.Decode(&encoded, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), tt.input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+}
+
+func TestLZEncodeChunks(t *testing.T) {
+	period := make([]byte, 40000)
+	rand.New(rand.NewSource(7)).Read(period)
+	input := bytes.Repeat(period, 3*lzChunkSize/len(period)+1)
+	for _, dict := range [][]byte{nil, period} {
+		var encoded, decoded bytes.Buffer
+		lzed := NewLZEncoderDecoder(dict)
+		if err := lzed.Encode(bytes.NewReader(input), &encoded); err != nil {
+			t.Fatalf("Unexpected encoding error: %s", err)
+		}
+		// matches continue across chunks, only the first period is literal
+		if encoded.Len() > 3*len(period) {
+			t.Errorf("Encoded data is too large: %d bytes", encoded.Len())
+		}
+		if err := lzed.Decode(&encoded, &decoded); err != nil {
+			t.Fatalf("Unexpected decoding error: %s", err)
+		}
+		if !bytes.Equal(decoded.Bytes(), input) {
+			t.Fatalf("Initial and decoded data are different")
+		}
+	}
+}
+
+func TestLZDictionaryImprovesSmallPayloads(t *testing.T) {
+	input := []byte(`{"timestamp":"2024-07-29T10:00:00Z","level":"info","message":"ok"}`)
+	var plain, primed bytes.Buffer
+	if err := NewLZEncoderDecoder(nil).Encode(bytes.NewReader(input), &plain); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if err := NewLZEncoderDecoder(lzDictionary).Encode(bytes.NewReader(input), &primed); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if primed.Len() >= plain.Len() {
+		t.Errorf("Dictionary does not help: %d bytes with dictionary, %d without", primed.Len(), plain.Len())
+	}
+}
+
+func TestLZDictionaryMismatch(t *testing.T) {
+	var encoded bytes.Buffer
+	input := []byte(`{"level":"info"}`)
+	if err := NewLZEncoderDecoder(lzDictionary).Encode(bytes.NewReader(input), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	for _, tt := range []struct {
+		name string
+		dict []byte
+	}{
+		{
+			name: "NoDictionary",
+		},
+		{
+			name: "OtherDictionary",
+			dict: []byte(`{"level":"debug"}`),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded bytes.Buffer
+			err := NewLZEncoderDecoder(tt.dict).Decode(bytes.NewReader(encoded.Bytes()), &decoded)
+			if !errors.Is(err, ErrDictionaryMismatch) {
+				t.Errorf("Expected ErrDictionaryMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLZCorruptedMatch(t *testing.T) {
+	// size 4, then match with distance 5 when nothing is decoded yet
+	stream := []byte{0, 4, 0, 0, 0, 0, 0, 0, 0, 1, 5, 0, 1}
+	var decoded bytes.Buffer
+	err := NewLZEncoderDecoder(nil).Decode(bytes.NewReader(stream), &decoded)
+	if !errors.Is(err, ErrCorruptedStream) {
+		t.Errorf("Expected ErrCorruptedStream, got %v", err)
+	}
+}
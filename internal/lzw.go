@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go-compressor/pkg/bits"
+	"io"
+)
+
+// LZW stream starts with byte keeping maximal code width in low bits and
+// reset policy in the high bit. Codes are written from 9 bits wide, width
+// grows with dictionary up to maximal one.
+const (
+	lzwClearCode = 256
+	lzwEndCode   = 257
+	lzwFirstCode = 258
+	lzwMinWidth  = 9
+
+	LZWMinMaxWidth     = 9
+	LZWMaxMaxWidth     = 16
+	LZWDefaultMaxWidth = 16
+
+	lzwWidthMask = 0x1f
+	lzwResetFlag = 0x80
+)
+
+type LZWResetPolicy int
+
+const (
+	// LZWReset emits clear code and starts new dictionary when it is full.
+	LZWReset LZWResetPolicy = iota
+	// LZWFreeze keeps full dictionary till the end of stream.
+	LZWFreeze
+)
+
+var lzwResetPolicyNames = map[LZWResetPolicy]string{
+	LZWReset:  "reset",
+	LZWFreeze: "freeze",
+}
+
+func ParseLZWResetPolicy(name string) (LZWResetPolicy, error) {
+	for p, n := range lzwResetPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return LZWReset, fmt.Errorf("unknown lzw reset policy '%s'", name)
+}
+
+func (p LZWResetPolicy) String() string {
+	if n, ok := lzwResetPolicyNames[p]; ok {
+		return n
+	}
+	return fmt.Sprintf("LZWResetPolicy(%d)", int(p))
+}
+
+// lzwWidth returns width enough for all codes below next.
+func lzwWidth(next, maxWidth int) int {
+	width := lzwMinWidth
+	for next > 1<<width && width < maxWidth {
+		width++
+	}
+	return width
+}
+
+type LZWEncoderDecoder struct {
+	maxWidth int
+	policy   LZWResetPolicy
+	progress ProgressFunc
+}
+
+// NewLZWEncoderDecoder returns classic LZW codec with codes of at most
+// maxWidth bits. Zero maxWidth means LZWDefaultMaxWidth.
+func NewLZWEncoderDecoder(maxWidth int, policy LZWResetPolicy) EncoderDecoder {
+	if maxWidth == 0 {
+		maxWidth = LZWDefaultMaxWidth
+	}
+	return &LZWEncoderDecoder{maxWidth: maxWidth, policy: policy}
+}
+
+func (lzwed *LZWEncoderDecoder) Encode(r io.ReadSeeker, w io.Writer) error {
+	if lzwed.maxWidth < LZWMinMaxWidth || lzwed.maxWidth > LZWMaxMaxWidth {
+		return fmt.Errorf("lzw code width %d is out of [%d, %d]", lzwed.maxWidth, LZWMinMaxWidth, LZWMaxMaxWidth)
+	}
+	bw := bufio.NewWriterSize(w, BufferSize)
+	hdr := byte(lzwed.maxWidth)
+	if lzwed.policy == LZWReset {
+		hdr |= lzwResetFlag
+	}
+	if err := bw.WriteByte(hdr); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(lzwed.progress.reader(r, StageEncoding), BufferSize)
+	bitw := bits.NewBitWriter(bw)
+	maxCode := 1 << lzwed.maxWidth
+	dict := make(map[uint32]int, maxCode)
+	next := lzwFirstCode
+	prefix := -1
+	for {
+		c, err := br.ReadByte()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if prefix < 0 {
+			prefix = int(c)
+			continue
+		}
+		key := uint32(prefix)<<8 | uint32(c)
+		if code, ok := dict[key]; ok {
+			prefix = code
+			continue
+		}
+		if err := bitw.WriteUint(uint64(prefix), lzwWidth(next, lzwed.maxWidth)); err != nil {
+			return err
+		}
+		if next < maxCode {
+			dict[key] = next
+			next++
+		} else if lzwed.policy == LZWReset {
+			if err := bitw.WriteUint(lzwClearCode, lzwed.maxWidth); err != nil {
+				return err
+			}
+			clear(dict)
+			next = lzwFirstCode
+		}
+		prefix = int(c)
+	}
+	if prefix >= 0 {
+		if err := bitw.WriteUint(uint64(prefix), lzwWidth(next, lzwed.maxWidth)); err != nil {
+			return err
+		}
+		// decoder adds entry after every code but the first one
+		if next < maxCode {
+			next++
+		}
+	}
+	if err := bitw.WriteUint(lzwEndCode, lzwWidth(next, lzwed.maxWidth)); err != nil {
+		return err
+	}
+	if err := bitw.Flush(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (lzwed *LZWEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(lzwed.progress.reader(r, StageDecoding), BufferSize)
+	hdr, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	maxWidth := int(hdr & lzwWidthMask)
+	reset := hdr&lzwResetFlag != 0
+	if maxWidth < LZWMinMaxWidth || maxWidth > LZWMaxMaxWidth {
+		return fmt.Errorf("%w: lzw code width %d", ErrCorruptedStream, maxWidth)
+	}
+
+	bw := bufio.NewWriterSize(w, BufferSize)
+	bitr := bits.NewBitReader(br)
+	maxCode := 1 << maxWidth
+	prefixes := make([]int32, maxCode)
+	suffixes := make([]byte, maxCode)
+	stack := make([]byte, 0, maxCode)
+	next := lzwFirstCode
+	prev := -1
+
+	// expand returns bytes of code in reverse order
+	expand := func(code int) []byte {
+		stack = stack[:0]
+		for code >= lzwFirstCode {
+			stack = append(stack, suffixes[code])
+			code = int(prefixes[code])
+		}
+		return append(stack, byte(code))
+	}
+
+	for {
+		pending := 0
+		if prev >= 0 {
+			pending = 1
+		}
+		c, err := bitr.ReadUint(lzwWidth(next+pending, maxWidth))
+		if err != nil {
+			return err
+		}
+		code := int(c)
+		switch {
+		case code == lzwEndCode:
+			return bw.Flush()
+		case code == lzwClearCode:
+			if !reset {
+				return fmt.Errorf("%w: unexpected lzw clear code", ErrCorruptedStream)
+			}
+			next, prev = lzwFirstCode, -1
+			continue
+		case code > next || (code == next && prev < 0):
+			return fmt.Errorf("%w: unknown lzw code %d", ErrCorruptedStream, code)
+		}
+
+		var s []byte
+		if code == next {
+			// code is being defined by this very step: prev + first byte of prev
+			s = expand(prev)
+			first := s[len(s)-1]
+			s = append([]byte{first}, s...)
+		} else {
+			s = expand(code)
+		}
+		if prev >= 0 && next < maxCode {
+			prefixes[next] = int32(prev)
+			suffixes[next] = s[len(s)-1]
+			next++
+		}
+		for i := len(s) - 1; i >= 0; i-- {
+			if err := bw.WriteByte(s[i]); err != nil {
+				return err
+			}
+		}
+		prev = code
+	}
+}
+
+var _ EncoderDecoder = &LZWEncoderDecoder{}
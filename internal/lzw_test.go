@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLZWEncodeDecode(t *testing.T) {
+	random := make([]byte, 1<<17)
+	rand.New(rand.NewSource(3)).Read(random)
+	vimbook, _ := os.ReadFile("../test/vimbook.pdf")
+	for _, in := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: []byte{}},
+		{name: "OneByte", input: []byte{'a'}},
+		{name: "Wiki", input: []byte("TOBEORNOTTOBEORTOBEORNOT#")},
+		{name: "KwKwK", input: []byte(strings.Repeat("a", 1000))},
+		{name: "Random", input: random},
+		{name: "VimBookPDF", input: vimbook[:1<<19]},
+	} {
+		for _, width := range []int{LZWMinMaxWidth, 12, LZWMaxMaxWidth} {
+			for _, policy := range []LZWResetPolicy{LZWReset, LZWFreeze} {
+				t.Run(fmt.Sprintf("%s/%d/%s", in.name, width, policy), func(t *testing.T) {
+					t.Parallel()
+					var encoded, decoded bytes.Buffer
+					if err := NewLZWEncoderDecoder(width, policy).Encode(bytes.NewReader(in.input), &encoded); err != nil {
+						t.Fatalf("Unexpected encoding error: %s", err)
+					}
+					// decoder takes width and policy from the stream
+					if err := NewLZWEncoderDecoder(0, LZWReset).Decode(&encoded, &decoded); err != nil {
+						t.Fatalf("Unexpected decoding error: %s", err)
+					}
+					if !bytes.Equal(decoded.Bytes(), in.input) {
+						t.Fatalf("Initial and decoded data are different")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestLZWCompressesText(t *testing.T) {
+	input := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000))
+	var encoded bytes.Buffer
+	if err := NewLZWEncoderDecoder(12, LZWReset).Encode(bytes.NewReader(input), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if encoded.Len()*10 > len(input) {
+		t.Errorf("Text is poorly compressed: %d bytes of %d", encoded.Len(), len(input))
+	}
+}
+
+func TestLZWInvalidStreams(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		stream []byte
+	}{
+		{
+			name:   "WidthOutOfRange",
+			stream: []byte{20},
+		},
+		{
+			// 9-bit code 300 is not defined yet
+			name:   "UnknownCode",
+			stream: []byte{LZWMinMaxWidth, 0x2c, 0x01},
+		},
+		{
+			// clear code in stream of freezing dictionary
+			name:   "UnexpectedClear",
+			stream: []byte{LZWMinMaxWidth, 0x00, 0x01},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded bytes.Buffer
+			err := NewLZWEncoderDecoder(0, LZWReset).Decode(bytes.NewReader(tt.stream), &decoded)
+			if !errors.Is(err, ErrCorruptedStream) {
+				t.Errorf("Expected ErrCorruptedStream, got %v", err)
+			}
+		})
+	}
+	var encoded bytes.Buffer
+	if err := NewLZWEncoderDecoder(LZWMaxMaxWidth+1, LZWReset).Encode(bytes.NewReader(nil), &encoded); err == nil {
+		t.Errorf("Too wide codes are accepted by encoder")
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// maxNameSize bounds length of file name stored in stream.
+const maxNameSize = 4096
+
+// FileMetadata describes original file, it is stored in stream header in
+// plain text even if blocks are encrypted.
+type FileMetadata struct {
+	// Name is base name of the file, it may be empty.
+	Name    string
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+func appendMetadata(dst []byte, m *FileMetadata) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(m.Name)))
+	dst = append(dst, m.Name...)
+	dst = binary.AppendUvarint(dst, uint64(m.Mode))
+	var mtime int64
+	if !m.ModTime.IsZero() {
+		mtime = m.ModTime.UnixNano()
+	}
+	return binary.AppendVarint(dst, mtime)
+}
+
+func readMetadata(r byteReader) (*FileMetadata, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxNameSize {
+		return nil, fmt.Errorf("%w: file name of %d bytes", ErrCorruptedStream, size)
+	}
+	name := make([]byte, size)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return nil, err
+	}
+	mode, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	mtime, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	m := &FileMetadata{Name: string(name), Mode: fs.FileMode(mode)}
+	if mtime != 0 {
+		m.ModTime = time.Unix(0, mtime)
+	}
+	return m, nil
+}
+
+// ReadMetadata reads metadata from header of stream, it returns nil if
+// stream has none.
+func ReadMetadata(r io.Reader) (*FileMetadata, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(streamMagic)); err != nil || string(magic) != streamMagic {
+		return nil, nil
+	}
+	h, err := readStreamHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return h.metadata, nil
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMetadata(t *testing.T) {
+	input := []byte("file with metadata")
+	for _, tt := range []struct {
+		name     string
+		metadata *FileMetadata
+	}{
+		{name: "None"},
+		{name: "Full", metadata: &FileMetadata{Name: "report.csv", Mode: 0o640, ModTime: time.Unix(1700000000, 123456789)}},
+		{name: "NoNameNoTime", metadata: &FileMetadata{Mode: 0o755}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := seekTestStream(t, input, Options{Metadata: tt.metadata})
+			m, err := ReadMetadata(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if (m == nil) != (tt.metadata == nil) ||
+				m != nil && (m.Name != tt.metadata.Name || m.Mode != tt.metadata.Mode || !m.ModTime.Equal(tt.metadata.ModTime)) {
+				t.Fatalf("Expected metadata %+v, got %+v", tt.metadata, m)
+			}
+			var decoded bytes.Buffer
+			if err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(encoded), &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), input) {
+				t.Fatalf("Decoded data differs from input")
+			}
+		})
+	}
+}
+
+func TestMetadataLegacyStream(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := NewHuffmanEncoderDecoder().Encode(bytes.NewReader([]byte("legacy")), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if m, err := ReadMetadata(&encoded); m != nil || err != nil {
+		t.Fatalf("Expected no metadata, got %+v, %v", m, err)
+	}
+}
+
+func TestMetadataTampering(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	metadata := &FileMetadata{Name: "report.csv", Mode: 0o640}
+	encoded := seekTestStream(t, []byte("encrypted file"), Options{Key: key, Metadata: metadata})
+	i := bytes.Index(encoded, []byte(metadata.Name))
+	encoded[i] = 'R'
+	if err := NewBlockEncoderDecoder(Options{Key: key}).Decode(bytes.NewReader(encoded), &bytes.Buffer{}); err == nil {
+		t.Fatalf("Stream with changed file name is decoded without error")
+	}
+}
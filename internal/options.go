@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MinLevel     = 1
+	MaxLevel     = 9
+	DefaultLevel = 6
+)
+
+type Strategy int
+
+const (
+	// StrategyAuto picks the best of other strategies for every block.
+	StrategyAuto Strategy = iota
+	StrategyHuffmanOnly
+	StrategyRLE
+	StrategyLZ
+	StrategyBWT
+)
+
+var strategyNames = map[Strategy]string{
+	StrategyAuto:        "auto",
+	StrategyHuffmanOnly: "huffman-only",
+	StrategyRLE:         "rle",
+	StrategyLZ:          "lz",
+	StrategyBWT:         "bwt",
+}
+
+func ParseStrategy(name string) (Strategy, error) {
+	for s, n := range strategyNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return StrategyAuto, fmt.Errorf("unknown strategy '%s'", name)
+}
+
+func (s Strategy) String() string {
+	if n, ok := strategyNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// StrategyNames lists names accepted by ParseStrategy.
+func StrategyNames() string {
+	names := make([]string, 0, len(strategyNames))
+	for s := StrategyAuto; int(s) < len(strategyNames); s++ {
+		names = append(names, s.String())
+	}
+	return strings.Join(names, ",")
+}
+
+type Entropy int
+
+const (
+	EntropyHuffman Entropy = iota
+	// EntropyTANS is table variant of asymmetric numeral systems coder.
+	EntropyTANS
+)
+
+var entropyNames = map[Entropy]string{
+	EntropyHuffman: "huffman",
+	EntropyTANS:    "tans",
+}
+
+func ParseEntropy(name string) (Entropy, error) {
+	for e, n := range entropyNames {
+		if n == name {
+			return e, nil
+		}
+	}
+	return EntropyHuffman, fmt.Errorf("unknown entropy coder '%s'", name)
+}
+
+func (e Entropy) String() string {
+	if n, ok := entropyNames[e]; ok {
+		return n
+	}
+	return fmt.Sprintf("Entropy(%d)", int(e))
+}
+
+// Options configure BlockEncoderDecoder. Zero value means default level
+// with automatic strategy selection.
+type Options struct {
+	Level    int
+	Strategy Strategy
+	// Entropy is coder applied after strategy transforms.
+	Entropy Entropy
+
+	// Table is used by huffman coder instead of per-block trees.
+	Table *HuffmanTable
+	// Dictionary primes LZ match window of every block.
+	Dictionary []byte
+	// Filters are applied to every block in order before compression.
+	Filters []Filter
+	// SeekIndex is written at the end of stream for SeekableReader.
+	SeekIndex bool
+	// Sync writes block sync markers and checksums, so that damaged stream
+	// can be recovered at cost of a few bytes per block.
+	Sync bool
+	// Passphrase or Key encrypt blocks, Key must be at least MinKeySize
+	// bytes long.
+	Passphrase []byte
+	Key        []byte
+	// Metadata of original file is stored in stream header.
+	Metadata *FileMetadata
+	// Progress is called as input is read.
+	Progress ProgressFunc
+	// SingleMember stops decoding after the first of concatenated streams.
+	SingleMember bool
+	// MaxOutputSize and MaxRatio (of output to input size) make decoding of
+	// untrusted streams fail with LimitError, zero means no limit.
+	MaxOutputSize int64
+	MaxRatio      float64
+
+	// LZWMaxWidth and LZWReset configure lzw algorithm.
+	LZWMaxWidth int
+	LZWReset    LZWResetPolicy
+
+	// scrypt overrides defaultScryptParams of passphrase encryption.
+	scrypt scryptParams
+}
+
+type levelParams struct {
+	blockSize  int
+	lzChain    int
+	sampleSize int
+	// exhaustive auto strategy compresses whole block with every candidate
+	// instead of sample.
+	exhaustive bool
+}
+
+func (o *Options) levelParams() levelParams {
+	level := o.Level
+	if level == 0 {
+		level = DefaultLevel
+	}
+	level = max(MinLevel, min(MaxLevel, level))
+	return levelParams{
+		blockSize:  1 << 16 << ((level - 1) / 2),
+		lzChain:    1 << level,
+		sampleSize: 1 << 12 << (level / 3),
+		exhaustive: level >= 8,
+	}
+}
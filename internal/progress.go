@@ -0,0 +1,70 @@
+package internal
+
+import "io"
+
+// Stage is pass over input reported by ProgressFunc. Huffman and RLE
+// encoders read input twice, first counting frequencies.
+type Stage int
+
+const (
+	StageFrequencies Stage = iota
+	StageEncoding
+	StageDecoding
+)
+
+func (s Stage) String() string {
+	switch s {
+	case StageFrequencies:
+		return "counting"
+	case StageEncoding:
+		return "encoding"
+	case StageDecoding:
+		return "decoding"
+	}
+	return "unknown"
+}
+
+// ProgressFunc is called with # of input bytes read by stage so far.
+type ProgressFunc func(stage Stage, read int64)
+
+// reader returns r reporting its reads to progress function.
+func (p ProgressFunc) reader(r io.Reader, stage Stage) io.Reader {
+	if p == nil {
+		return r
+	}
+	return &progressReader{r: r, progress: p, stage: stage}
+}
+
+type progressReader struct {
+	r        io.Reader
+	progress ProgressFunc
+	stage    Stage
+	read     int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.read += int64(n)
+		pr.progress(pr.stage, pr.read)
+	}
+	return n, err
+}
+
+// progressSetter is implemented by raw codecs, whose constructors do not
+// take progress function.
+type progressSetter interface {
+	setProgress(p ProgressFunc)
+}
+
+func (hmed *HuffmanEncoderDecoder) setProgress(p ProgressFunc) { hmed.progress = p }
+func (lzed *LZEncoderDecoder) setProgress(p ProgressFunc)      { lzed.progress = p }
+func (lzwed *LZWEncoderDecoder) setProgress(p ProgressFunc)    { lzwed.progress = p }
+func (rled *RLEEncoderDecoder) setProgress(p ProgressFunc)     { rled.progress = p }
+
+var (
+	_ progressSetter = &HuffmanEncoderDecoder{}
+	_ progressSetter = &LZEncoderDecoder{}
+	_ progressSetter = &LZWEncoderDecoder{}
+	_ progressSetter = &RLEEncoderDecoder{}
+)
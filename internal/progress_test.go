@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	input := []byte(strings.Repeat("progress is reported while input is read\n", 10000))
+	for _, tt := range []struct {
+		algorithm string
+		stages    []Stage
+	}{
+		{algorithm: DefaultAlgorithm, stages: []Stage{StageEncoding}},
+		{algorithm: "huffman", stages: []Stage{StageFrequencies, StageEncoding}},
+		{algorithm: "lz", stages: []Stage{StageEncoding}},
+		{algorithm: "lzw", stages: []Stage{StageEncoding}},
+		{algorithm: "rle", stages: []Stage{StageFrequencies, StageEncoding}},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			read := map[Stage]int64{}
+			var order []Stage
+			progress := func(stage Stage, n int64) {
+				if n < read[stage] {
+					t.Errorf("Progress of %s goes back from %d to %d", stage, read[stage], n)
+				}
+				if len(order) == 0 || order[len(order)-1] != stage {
+					order = append(order, stage)
+				}
+				read[stage] = n
+			}
+			enc, err := NewEncoderDecoder(tt.algorithm, Options{Progress: progress})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			var encoded bytes.Buffer
+			if err := enc.Encode(bytes.NewReader(input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if len(order) != len(tt.stages) {
+				t.Fatalf("Expected stages %v, got %v", tt.stages, order)
+			}
+			for i, stage := range tt.stages {
+				if order[i] != stage || read[stage] != int64(len(input)) {
+					t.Fatalf("Expected %s of %d bytes, got %s of %d", stage, len(input), order[i], read[order[i]])
+				}
+			}
+
+			encodedSize := int64(encoded.Len())
+			if err := enc.Decode(&encoded, &bytes.Buffer{}); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if read[StageDecoding] != encodedSize {
+				t.Fatalf("Expected decoding of %d bytes, got %d", encodedSize, read[StageDecoding])
+			}
+		})
+	}
+}
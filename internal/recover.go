@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// recoverBufferSize fits the largest block with its header.
+const recoverBufferSize = maxStageSize + maxBlockHeaderSize
+
+var ErrNotRecoverable = errors.New("stream has no block sync markers")
+
+// LostRange is range of uncompressed data that could not be recovered. End
+// is -1 when stream is truncated and size of lost data is unknown.
+type LostRange struct {
+	Start, End int64
+}
+
+type RecoveryReport struct {
+	// Blocks and Bytes count recovered blocks and bytes.
+	Blocks int
+	Bytes  int64
+	Lost   []LostRange
+	// HeaderLost tells that blocks were found before any stream header, so
+	// they were decoded without filters.
+	HeaderLost bool
+}
+
+func (rr *RecoveryReport) lose(start, end int64) {
+	if start == end {
+		return
+	}
+	if n := len(rr.Lost); n > 0 && rr.Lost[n-1].End == start {
+		rr.Lost[n-1].End = end
+		return
+	}
+	rr.Lost = append(rr.Lost, LostRange{Start: start, End: end})
+}
+
+// recoverer scans damaged stream for stream headers and block sync markers.
+// Offsets of concatenated streams are counted from the start of the first
+// one.
+type recoverer struct {
+	bed    *BlockEncoderDecoder
+	report RecoveryReport
+
+	bc    *blockCodec
+	flags byte
+	// base is offset of current stream, next is expected offset of block
+	// inside it and ended is set after its end marker.
+	base, next int64
+	ended      bool
+	started    bool
+	// headerErr is the first error of configuring codec of stream header,
+	// returned if nothing is recovered.
+	headerErr error
+
+	block []byte
+}
+
+// Recover decodes all blocks of damaged stream that have valid checksums,
+// skipping bad ones, and reports which uncompressed ranges were lost. Only
+// streams written with Options.Sync can be recovered.
+func Recover(r io.Reader, w io.Writer, opts Options) (*RecoveryReport, error) {
+	br := bufio.NewReaderSize(r, recoverBufferSize)
+	bw := bufio.NewWriterSize(w, BufferSize)
+	rc := &recoverer{bed: &BlockEncoderDecoder{opts: opts}}
+	for {
+		buf, err := br.Peek(recoverBufferSize)
+		if len(buf) == 0 {
+			break
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		i := nextCandidate(buf)
+		if i < 0 {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			// keep tail which may be the start of a marker
+			_, _ = br.Discard(len(buf) - len(syncMarker) + 1)
+			continue
+		}
+		n, werr := rc.try(buf[i:], bw)
+		if werr != nil {
+			return nil, werr
+		}
+		if n == 0 {
+			n = 1
+		}
+		_, _ = br.Discard(i + n)
+	}
+	if rc.report.Blocks == 0 && rc.headerErr != nil {
+		return nil, rc.headerErr
+	}
+	if !rc.started && rc.report.Blocks == 0 {
+		return nil, ErrNotRecoverable
+	}
+	if !rc.ended {
+		rc.report.lose(rc.base+rc.next, -1)
+	}
+	return &rc.report, bw.Flush()
+}
+
+// nextCandidate returns index of the first stream header or sync marker.
+func nextCandidate(buf []byte) int {
+	i := bytes.Index(buf, []byte(syncMarker))
+	if j := bytes.Index(buf, []byte(streamMagic)); j >= 0 && (i < 0 || j < i) {
+		return j
+	}
+	return i
+}
+
+// try decodes stream header or block at the start of buf, returning its size
+// or 0 if it is damaged.
+func (rc *recoverer) try(buf []byte, w io.Writer) (int, error) {
+	rd := bytes.NewReader(buf)
+	if bytes.HasPrefix(buf, []byte(streamMagic)) {
+		h, err := readStreamHeader(rd)
+		if err != nil || h.flags&flagBlockSync == 0 {
+			return 0, nil
+		}
+		bc, err := rc.bed.decodingCodec(h)
+		if err != nil {
+			// header may be false positive inside damaged block
+			if rc.headerErr == nil {
+				rc.headerErr = err
+			}
+			return 0, nil
+		}
+		if rc.started && !rc.ended {
+			rc.report.lose(rc.base+rc.next, -1)
+		}
+		rc.base += rc.next
+		rc.next, rc.ended, rc.started = 0, false, true
+		rc.bc, rc.flags = bc, h.flags
+		return len(buf) - rd.Len(), nil
+	}
+
+	if !rc.started {
+		rc.report.HeaderLost = true
+		rc.bc, rc.flags, rc.started = newBlockCodec(&rc.bed.opts), flagBlockSync, true
+	}
+	bh, err := readBlockHeader(rd, rc.flags)
+	if err != nil || bh.payloadSize > rd.Len() {
+		return 0, nil
+	}
+	payload := buf[len(buf)-rd.Len():][:bh.payloadSize]
+	if bh.verify(rc.flags, payload) != nil || int64(bh.rawOffset) < rc.next {
+		return 0, nil
+	}
+	size := len(buf) - rd.Len() + bh.payloadSize
+	offset := int64(bh.rawOffset)
+	rc.report.lose(rc.base+rc.next, rc.base+offset)
+	if bh.method == blockEnd {
+		rc.next, rc.ended = offset, true
+		return size, nil
+	}
+	rc.next = offset + int64(bh.rawSize)
+	if rc.bc.aead != nil {
+		// peeked buffer must not be modified
+		if payload, err = rc.bc.open(bh, rc.flags, append([]byte{}, payload...)); err != nil {
+			rc.report.lose(rc.base+offset, rc.base+rc.next)
+			return size, nil
+		}
+	}
+	if rc.block, err = rc.bc.decompressBlock(rc.block[:0], bh.method, payload, bh.rawSize); err != nil {
+		rc.report.lose(rc.base+offset, rc.base+rc.next)
+		return size, nil
+	}
+	rc.bc.revertFilters(rc.block)
+	if _, err := w.Write(rc.block); err != nil {
+		return 0, err
+	}
+	rc.report.Blocks++
+	rc.report.Bytes += int64(bh.rawSize)
+	return size, nil
+}
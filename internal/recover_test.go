@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func recoverTestStream(t *testing.T, opts Options) ([]byte, []byte, *seekIndex) {
+	t.Helper()
+	input := make([]byte, 5*opts.levelParams().blockSize+100)
+	rng := rand.New(rand.NewSource(3))
+	for i := range input {
+		input[i] = byte('a' + rng.Intn(4))
+	}
+	opts.SeekIndex, opts.Sync = true, true
+	encoded := seekTestStream(t, input, opts)
+	index, err := readSeekIndex(bytes.NewReader(encoded), int64(len(encoded)), newStreamHeader(&opts).flags)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	return input, encoded, index
+}
+
+func TestRecover(t *testing.T) {
+	opts := Options{Level: MinLevel, Filters: []Filter{{Kind: FilterDelta}}}
+	blockSize := int64(opts.levelParams().blockSize)
+	input, encoded, index := recoverTestStream(t, opts)
+	// damage payload of the second and header of the fourth block
+	encoded[index.entries[1].blockOffset+20] ^= 0xff
+	encoded[index.entries[3].blockOffset+5] ^= 0xff
+
+	err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(encoded), &bytes.Buffer{})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("Expected %v, got %v", ErrChecksumMismatch, err)
+	}
+
+	var recovered bytes.Buffer
+	report, err := Recover(bytes.NewReader(encoded), &recovered, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected recovery error: %s", err)
+	}
+	lost := []LostRange{{Start: blockSize, End: 2 * blockSize}, {Start: 3 * blockSize, End: 4 * blockSize}}
+	if !reflect.DeepEqual(report.Lost, lost) {
+		t.Fatalf("Expected lost ranges %v, got %v", lost, report.Lost)
+	}
+	if report.Blocks != 4 || report.Bytes != int64(len(input))-2*blockSize || report.HeaderLost {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	expected := append(append(append([]byte{}, input[:blockSize]...),
+		input[2*blockSize:3*blockSize]...), input[4*blockSize:]...)
+	if !bytes.Equal(recovered.Bytes(), expected) {
+		t.Fatalf("Recovered data differs from undamaged blocks")
+	}
+}
+
+func TestRecoverTruncated(t *testing.T) {
+	opts := Options{Level: MinLevel}
+	blockSize := int64(opts.levelParams().blockSize)
+	input, encoded, index := recoverTestStream(t, opts)
+	// lose stream header and everything after the third block
+	encoded[0] ^= 0xff
+	encoded = encoded[:index.entries[3].blockOffset+10]
+
+	var recovered bytes.Buffer
+	report, err := Recover(bytes.NewReader(encoded), &recovered, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected recovery error: %s", err)
+	}
+	lost := []LostRange{{Start: 3 * blockSize, End: -1}}
+	if !reflect.DeepEqual(report.Lost, lost) || !report.HeaderLost {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	if !bytes.Equal(recovered.Bytes(), input[:3*blockSize]) {
+		t.Fatalf("Recovered data differs from undamaged blocks")
+	}
+}
+
+func TestRecoverNoSync(t *testing.T) {
+	encoded := seekTestStream(t, []byte("unframed stream"), Options{})
+	if _, err := Recover(bytes.NewReader(encoded), &bytes.Buffer{}, Options{}); !errors.Is(err, ErrNotRecoverable) {
+		t.Fatalf("Expected %v, got %v", ErrNotRecoverable, err)
+	}
+}
+
+func TestRecoverFalseHeader(t *testing.T) {
+	opts := Options{Level: MinLevel, Sync: true}
+	blockSize := opts.levelParams().blockSize
+	input := make([]byte, 3*blockSize)
+	rand.New(rand.NewSource(5)).Read(input)
+	// stored block contains header of stream with unknown dictionary
+	copy(input[blockSize+100:], streamMagic+"\x01\x09\x01\x02\x03\x04")
+	encoded := seekTestStream(t, input, opts)
+	start := bytes.Index(encoded, input[blockSize:blockSize+16])
+	if start < 0 {
+		t.Fatalf("Second block is not stored")
+	}
+	encoded[start+10] ^= 0xff
+
+	var recovered bytes.Buffer
+	report, err := Recover(bytes.NewReader(encoded), &recovered, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected recovery error: %s", err)
+	}
+	lost := []LostRange{{Start: int64(blockSize), End: int64(2 * blockSize)}}
+	if !reflect.DeepEqual(report.Lost, lost) || report.Blocks != 2 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	expected := append(append([]byte{}, input[:blockSize]...), input[2*blockSize:]...)
+	if !bytes.Equal(recovered.Bytes(), expected) {
+		t.Fatalf("Recovered data differs from undamaged blocks")
+	}
+}
+
+func TestRecoverNoKey(t *testing.T) {
+	encoded := encryptTestStream(t, []byte(strings.Repeat("secret ", 1000)), Options{Key: []byte("0123456789abcdef"), Sync: true})
+	if _, err := Recover(bytes.NewReader(encoded), &bytes.Buffer{}, Options{}); !errors.Is(err, ErrKeyRequired) {
+		t.Fatalf("Expected %v, got %v", ErrKeyRequired, err)
+	}
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Run of rleMinRun..rleMaxRun equal bytes is encoded as escape byte, run
+// length code and repeated byte. Escape byte itself is encoded as escape
+// followed by zero. Escape is chosen as the least frequent byte of input and
+// is stored as the first byte of output.
+const (
+	rleMinRun = 4
+	rleMaxRun = rleMinRun + 254
+)
+
+func leastFrequentByte(fc frequencyCounter) byte {
+	least := 0
+	for b := 0; b < bytesCount; b++ {
+		if fc.frequencyOf(byte(b)) < fc.frequencyOf(byte(least)) {
+			least = b
+		}
+	}
+	return byte(least)
+}
+
+func appendRun(dst []byte, esc, b byte, run int) []byte {
+	switch {
+	case run >= rleMinRun:
+		return append(dst, esc, byte(run-rleMinRun+1), b)
+	case b == esc:
+		for k := 0; k < run; k++ {
+			dst = append(dst, esc, 0)
+		}
+	default:
+		for k := 0; k < run; k++ {
+			dst = append(dst, b)
+		}
+	}
+	return dst
+}
+
+func rleEncode(dst, src []byte) []byte {
+	if len(src) == 0 {
+		return dst
+	}
+	fa, _ := newFrequencyArray(bytes.NewReader(src))
+	esc := leastFrequentByte(fa)
+	dst = append(dst, esc)
+	for i := 0; i < len(src); {
+		run := 1
+		for i+run < len(src) && src[i+run] == src[i] && run < rleMaxRun {
+			run++
+		}
+		dst = appendRun(dst, esc, src[i], run)
+		i += run
+	}
+	return dst
+}
+
+// rleDecode appends at most size decoded bytes of src to dst.
+func rleDecode(dst, src []byte, size int) ([]byte, error) {
+	if len(src) == 0 {
+		return dst, nil
+	}
+	buf := bytes.NewBuffer(dst)
+	if err := rleDecodeStream(bytes.NewReader(src[1:]), buf, src[0], uint64(size)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rleDecodeStream fails with ErrCorruptedStream before more than size bytes
+// are written to w.
+func rleDecodeStream(r io.ByteReader, w io.ByteWriter, esc byte, size uint64) error {
+	for written := uint64(0); ; {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if written == size {
+			return fmt.Errorf("%w: run exceeds decoded size", ErrCorruptedStream)
+		}
+		if b != esc {
+			if err := w.WriteByte(b); err != nil {
+				return err
+			}
+			written++
+			continue
+		}
+
+		code, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("%w: truncated run", ErrCorruptedStream)
+		}
+		if code == 0 {
+			if err := w.WriteByte(esc); err != nil {
+				return err
+			}
+			written++
+			continue
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return fmt.Errorf("%w: truncated run", ErrCorruptedStream)
+		}
+		run := uint64(code) + rleMinRun - 1
+		if run > size-written {
+			return fmt.Errorf("%w: run exceeds decoded size", ErrCorruptedStream)
+		}
+		written += run
+		for k := uint64(0); k < run; k++ {
+			if err := w.WriteByte(b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type RLEEncoderDecoder struct {
+	progress ProgressFunc
+}
+
+// NewRLEEncoderDecoder returns standalone run-length codec. Its output is
+// the same as of RLE pre-pass of StrategyRLE blocks.
+func NewRLEEncoderDecoder() EncoderDecoder {
+	return &RLEEncoderDecoder{}
+}
+
+func (rled *RLEEncoderDecoder) Encode(r io.ReadSeeker, w io.Writer) error {
+	fa, err := newFrequencyArray(rled.progress.reader(r, StageFrequencies))
+	if err != nil {
+		return err
+	}
+	if fa.total() == 0 {
+		return nil
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(rled.progress.reader(r, StageEncoding), BufferSize)
+	bw := bufio.NewWriterSize(w, BufferSize)
+	esc := leastFrequentByte(fa)
+	if err := bw.WriteByte(esc); err != nil {
+		return err
+	}
+
+	var run [3]byte
+	last, cnt := byte(0), 0
+	for {
+		b, err := br.ReadByte()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if cnt > 0 && (err != nil || b != last || cnt == rleMaxRun) {
+			if _, err := bw.Write(appendRun(run[:0], esc, last, cnt)); err != nil {
+				return err
+			}
+			cnt = 0
+		}
+		if err != nil {
+			break
+		}
+		last = b
+		cnt++
+	}
+	return bw.Flush()
+}
+
+func (rled *RLEEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
+	br := bufio.NewReaderSize(rled.progress.reader(r, StageDecoding), BufferSize)
+	esc, err := br.ReadByte()
+	if errors.Is(err, io.EOF) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(w, BufferSize)
+	if err := rleDecodeStream(br, bw, esc, math.MaxUint64); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+var _ EncoderDecoder = &RLEEncoderDecoder{}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRLEEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    []byte
+		expected []byte
+	}{
+		{
+			name:     "ShortRuns",
+			input:    []byte("aabbbc"),
+			expected: []byte("\x00aabbbc"),
+		},
+		{
+			name:     "LongRun",
+			input:    []byte("abbbbbbc"),
+			expected: []byte("\x00a\x00\x03bc"),
+		},
+		{
+			name:     "EscapedLiteral",
+			input:    []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 0},
+			expected: []byte{16, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 0},
+		},
+		{
+			name:  "EmptyInput",
+			input: []byte{},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := rleEncode(nil, tt.input)
+			if !bytes.Equal(encoded, tt.expected) {
+				t.Errorf("RLE differs: expected %q, got %q", tt.expected, encoded)
+			}
+			decoded, err := rleDecode(nil, encoded, len(tt.input))
+			if err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded, tt.input) {
+				t.Errorf("Decoded data differs: expected %q, got %q", tt.input, decoded)
+			}
+		})
+	}
+}
+
+func TestRLEEncoderDecoder(t *testing.T) {
+	for _, tt := range testInputs() {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			rled := NewRLEEncoderDecoder()
+			if err := rled.Encode(bytes.NewReader(tt.input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if expected := rleEncode(nil, tt.input); !bytes.Equal(encoded.Bytes(), expected) {
+				t.Errorf("Standalone RLE differs from block pre-pass")
+			}
+			if tt.runs && encoded.Len() > len(tt.input)/2 {
+				t.Errorf("Runs are not compressed: %d bytes of %d", encoded.Len(), len(tt.input))
+			}
+			if err := rled.Decode(&encoded, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded.Bytes(), tt.input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+}
+
+func TestRLEStrategyBelowOneBitPerByte(t *testing.T) {
+	for _, tt := range testInputs() {
+		if !tt.runs {
+			continue
+		}
+		t.Run(tt.name, func(t *testing.T) {
+			var rle, huffman bytes.Buffer
+			err := NewBlockEncoderDecoder(Options{Strategy: StrategyRLE}).Encode(bytes.NewReader(tt.input), &rle)
+			if err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			err = NewBlockEncoderDecoder(Options{Strategy: StrategyHuffmanOnly}).Encode(bytes.NewReader(tt.input), &huffman)
+			if err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if rle.Len() >= huffman.Len() {
+				t.Errorf("RLE pre-pass does not help: %d bytes with it, %d without", rle.Len(), huffman.Len())
+			}
+			if rle.Len()*byteBits >= len(tt.input) {
+				t.Errorf("RLE pre-pass takes %d bytes, not less than 1 bit per byte", rle.Len())
+			}
+		})
+	}
+}
+
+func TestRLEDecodeTruncated(t *testing.T) {
+	for _, stream := range [][]byte{{0, 'a', 0}, {0, 'a', 0, 5}} {
+		if _, err := rleDecode(nil, stream, 16); !errors.Is(err, ErrCorruptedStream) {
+			t.Errorf("Expected ErrCorruptedStream for %v, got %v", stream, err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+var ErrScryptParams = errors.New("invalid scrypt parameters")
+
+// scryptParams are cost parameters of scrypt key derivation (RFC 7914).
+type scryptParams struct {
+	logN, r, p byte
+}
+
+var defaultScryptParams = scryptParams{logN: 15, r: 8, p: 1}
+
+// maxScryptMemory bounds 128*r*N bytes of scryptROMix table and
+// maxScryptWork bounds p*r*N, which is proportional to hashing time. Default
+// parameters take 32 MiB and 1/16 of the work.
+const (
+	maxScryptMemory = 256 << 20
+	maxScryptWork   = 1 << 22
+)
+
+// validate bounds memory and time spent on parameters read from stream.
+func (sp scryptParams) validate() error {
+	if sp.logN < 1 || sp.logN > 30 || sp.r < 1 || sp.p < 1 {
+		return ErrScryptParams
+	}
+	n := uint64(1) << sp.logN
+	if 128*uint64(sp.r)*n > maxScryptMemory || uint64(sp.p)*uint64(sp.r)*n > maxScryptWork {
+		return ErrScryptParams
+	}
+	return nil
+}
+
+func scrypt(password, salt []byte, sp scryptParams, keyLen int) []byte {
+	n, r, p := 1<<sp.logN, int(sp.r), int(sp.p)
+	b := pbkdf2SHA256(password, salt, p*128*r)
+	x := make([]uint32, 32*r)
+	v := make([]uint32, 32*r*n)
+	for i := 0; i < p; i++ {
+		scryptROMix(b[i*128*r:][:128*r], x, v, r, n)
+	}
+	return pbkdf2SHA256(password, b, keyLen)
+}
+
+// pbkdf2SHA256 is PBKDF2 with HMAC-SHA256 and single iteration, as used by
+// scrypt.
+func pbkdf2SHA256(password, salt []byte, keyLen int) []byte {
+	mac := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+sha256.Size)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		mac.Reset()
+		mac.Write(salt)
+		mac.Write(binary.BigEndian.AppendUint32(nil, i))
+		key = mac.Sum(key)
+	}
+	return key[:keyLen]
+}
+
+func scryptROMix(b []byte, x, v []uint32, r, n int) {
+	for i := range x {
+		x[i] = binary.LittleEndian.Uint32(b[4*i:])
+	}
+	y := make([]uint32, len(x))
+	for i := 0; i < n; i++ {
+		copy(v[i*len(x):], x)
+		scryptBlockMix(x, y, r)
+	}
+	for i := 0; i < n; i++ {
+		j := int(x[(2*r-1)*16]) & (n - 1)
+		for k, vk := range v[j*len(x) : (j+1)*len(x)] {
+			x[k] ^= vk
+		}
+		scryptBlockMix(x, y, r)
+	}
+	for i, xi := range x {
+		binary.LittleEndian.PutUint32(b[4*i:], xi)
+	}
+}
+
+// scryptBlockMix mixes 2*r 64-byte blocks of b using y as scratch.
+func scryptBlockMix(b, y []uint32, r int) {
+	var t [16]uint32
+	copy(t[:], b[(2*r-1)*16:])
+	for i := 0; i < 2*r; i++ {
+		for j := range t {
+			t[j] ^= b[i*16+j]
+		}
+		salsa208(&t)
+		// even blocks go to the first half, odd ones to the second
+		copy(y[(i/2+(i%2)*r)*16:], t[:])
+	}
+	copy(b, y)
+}
+
+func salsa208(b *[16]uint32) {
+	x := *b
+	for i := 0; i < 8; i += 2 {
+		x[4] ^= bits.RotateLeft32(x[0]+x[12], 7)
+		x[8] ^= bits.RotateLeft32(x[4]+x[0], 9)
+		x[12] ^= bits.RotateLeft32(x[8]+x[4], 13)
+		x[0] ^= bits.RotateLeft32(x[12]+x[8], 18)
+		x[9] ^= bits.RotateLeft32(x[5]+x[1], 7)
+		x[13] ^= bits.RotateLeft32(x[9]+x[5], 9)
+		x[1] ^= bits.RotateLeft32(x[13]+x[9], 13)
+		x[5] ^= bits.RotateLeft32(x[1]+x[13], 18)
+		x[14] ^= bits.RotateLeft32(x[10]+x[6], 7)
+		x[2] ^= bits.RotateLeft32(x[14]+x[10], 9)
+		x[6] ^= bits.RotateLeft32(x[2]+x[14], 13)
+		x[10] ^= bits.RotateLeft32(x[6]+x[2], 18)
+		x[3] ^= bits.RotateLeft32(x[15]+x[11], 7)
+		x[7] ^= bits.RotateLeft32(x[3]+x[15], 9)
+		x[11] ^= bits.RotateLeft32(x[7]+x[3], 13)
+		x[15] ^= bits.RotateLeft32(x[11]+x[7], 18)
+
+		x[1] ^= bits.RotateLeft32(x[0]+x[3], 7)
+		x[2] ^= bits.RotateLeft32(x[1]+x[0], 9)
+		x[3] ^= bits.RotateLeft32(x[2]+x[1], 13)
+		x[0] ^= bits.RotateLeft32(x[3]+x[2], 18)
+		x[6] ^= bits.RotateLeft32(x[5]+x[4], 7)
+		x[7] ^= bits.RotateLeft32(x[6]+x[5], 9)
+		x[4] ^= bits.RotateLeft32(x[7]+x[6], 13)
+		x[5] ^= bits.RotateLeft32(x[4]+x[7], 18)
+		x[11] ^= bits.RotateLeft32(x[10]+x[9], 7)
+		x[8] ^= bits.RotateLeft32(x[11]+x[10], 9)
+		x[9] ^= bits.RotateLeft32(x[8]+x[11], 13)
+		x[10] ^= bits.RotateLeft32(x[9]+x[8], 18)
+		x[12] ^= bits.RotateLeft32(x[15]+x[14], 7)
+		x[13] ^= bits.RotateLeft32(x[12]+x[15], 9)
+		x[14] ^= bits.RotateLeft32(x[13]+x[12], 13)
+		x[15] ^= bits.RotateLeft32(x[14]+x[13], 18)
+	}
+	for i := range b {
+		b[i] += x[i]
+	}
+}
@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestScrypt(t *testing.T) {
+	// test vectors of RFC 7914
+	for _, tt := range []struct {
+		password, salt string
+		params         scryptParams
+		key            string
+	}{
+		{
+			params: scryptParams{logN: 4, r: 1, p: 1},
+			key: "77d6576238657b203b19ca42c18a0497f16b4844e3074ae8dfdffa3fede21442" +
+				"fcd0069ded0948f8326a753a0fc81f17e8d3e0fb2e0d3628cf35e20c38d18906",
+		},
+		{
+			password: "password",
+			salt:     "NaCl",
+			params:   scryptParams{logN: 10, r: 8, p: 16},
+			key: "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162" +
+				"2eaf30d92e22a3886ff109279d9830dac727afb94a83ee6d8360cbdfa2cc0640",
+		},
+	} {
+		key := scrypt([]byte(tt.password), []byte(tt.salt), tt.params, 64)
+		if hex.EncodeToString(key) != tt.key {
+			t.Errorf("Expected key %s, got %x", tt.key, key)
+		}
+	}
+}
+
+func TestScryptParamsValidate(t *testing.T) {
+	for _, tt := range []struct {
+		params scryptParams
+		valid  bool
+	}{
+		{params: defaultScryptParams, valid: true},
+		{params: scryptParams{logN: 10, r: 8, p: 16}, valid: true},
+		{params: scryptParams{logN: 20, r: 32, p: 1}},
+		{params: scryptParams{logN: 18, r: 16, p: 1}},
+		{params: scryptParams{logN: 15, r: 8, p: 255}},
+		{params: scryptParams{logN: 255, r: 1, p: 1}},
+		{params: scryptParams{logN: 10, r: 0, p: 1}},
+	} {
+		if err := tt.params.validate(); (err == nil) != tt.valid {
+			t.Errorf("Unexpected validation result of %+v: %v", tt.params, err)
+		}
+	}
+}
+
+func TestReadEncryptionHeaderOversized(t *testing.T) {
+	eh := &encryptionHeader{kdf: kdfScrypt, scrypt: scryptParams{logN: 20, r: 32, p: 16}}
+	if _, err := readEncryptionHeader(bytes.NewReader(eh.appendTo(nil))); !errors.Is(err, ErrScryptParams) {
+		t.Errorf("Expected invalid scrypt parameters error, got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Seek index follows end of stream marker: # of blocks, raw and compressed
+// offsets of every block and of end marker, then # of blocks again and magic,
+// so that index can be found from the end of stream.
+const (
+	seekIndexMagic     = "gidx"
+	seekIndexEntrySize = 8 + 8
+	seekFooterSize     = 4 + len(seekIndexMagic)
+)
+
+var (
+	ErrNoSeekIndex     = errors.New("stream has no seek index")
+	ErrSeekIndexMember = errors.New("seek index does not cover concatenated streams")
+)
+
+type seekEntry struct {
+	rawOffset   uint64
+	blockOffset uint64
+}
+
+type seekIndex struct {
+	entries []seekEntry
+}
+
+func (si *seekIndex) add(rawOffset, blockOffset uint64) {
+	si.entries = append(si.entries, seekEntry{rawOffset: rawOffset, blockOffset: blockOffset})
+}
+
+// writeTo writes index, whose last entry points to end marker.
+func (si *seekIndex) writeTo(w io.Writer) error {
+	blocks := uint32(len(si.entries) - 1)
+	buf := binary.LittleEndian.AppendUint32(nil, blocks)
+	for _, e := range si.entries {
+		buf = binary.LittleEndian.AppendUint64(buf, e.rawOffset)
+		buf = binary.LittleEndian.AppendUint64(buf, e.blockOffset)
+	}
+	buf = binary.LittleEndian.AppendUint32(buf, blocks)
+	buf = append(buf, seekIndexMagic...)
+	_, err := w.Write(buf)
+	return err
+}
+
+// skipSeekIndex reads index following end marker in sequential stream.
+func skipSeekIndex(r io.Reader) error {
+	var blocks uint32
+	if err := binary.Read(r, binary.LittleEndian, &blocks); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(io.Discard, r, (int64(blocks)+1)*seekIndexEntrySize); err != nil {
+		return err
+	}
+	footer := make([]byte, seekFooterSize)
+	if _, err := io.ReadFull(r, footer); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(footer) != blocks || string(footer[4:]) != seekIndexMagic {
+		return fmt.Errorf("%w: invalid seek index", ErrCorruptedStream)
+	}
+	return nil
+}
+
+// readSeekIndex reads index of stream with given flags, which must be the only
+// one in r.
+func readSeekIndex(r io.ReaderAt, size int64, flags byte) (*seekIndex, error) {
+	footer := make([]byte, seekFooterSize)
+	if size < int64(seekFooterSize) {
+		return nil, ErrNoSeekIndex
+	}
+	if _, err := r.ReadAt(footer, size-int64(seekFooterSize)); err != nil {
+		return nil, err
+	}
+	if string(footer[4:]) != seekIndexMagic {
+		return nil, ErrNoSeekIndex
+	}
+	blocks := int64(binary.LittleEndian.Uint32(footer))
+	indexSize := (blocks + 1) * seekIndexEntrySize
+	if indexSize > size-int64(seekFooterSize) {
+		return nil, fmt.Errorf("%w: invalid seek index", ErrCorruptedStream)
+	}
+	buf := make([]byte, indexSize)
+	if _, err := r.ReadAt(buf, size-int64(seekFooterSize)-indexSize); err != nil {
+		return nil, err
+	}
+	si := &seekIndex{entries: make([]seekEntry, blocks+1)}
+	for i := range si.entries {
+		e := buf[i*seekIndexEntrySize:]
+		si.entries[i] = seekEntry{
+			rawOffset:   binary.LittleEndian.Uint64(e),
+			blockOffset: binary.LittleEndian.Uint64(e[8:]),
+		}
+		if i > 0 && (si.entries[i].rawOffset < si.entries[i-1].rawOffset ||
+			si.entries[i].blockOffset <= si.entries[i-1].blockOffset) {
+			return nil, fmt.Errorf("%w: invalid seek index", ErrCorruptedStream)
+		}
+	}
+	// end marker directly precedes index and its # of blocks
+	end := si.entries[blocks]
+	endMarker := &blockHeader{method: blockEnd, rawOffset: end.rawOffset}
+	endSize := endMarker.size(flags)
+	if flags&flagEncrypted != 0 {
+		endSize += encryptionTagSize
+	}
+	if end.blockOffset+uint64(endSize)+4 != uint64(size-int64(seekFooterSize)-indexSize) {
+		return nil, ErrSeekIndexMember
+	}
+	return si, nil
+}
+
+// SeekableReader decompresses stream written with Options.SeekIndex. It
+// decodes only blocks covering requested ranges.
+type SeekableReader struct {
+	r     io.ReaderAt
+	bc    *blockCodec
+	flags byte
+	index *seekIndex
+
+	mu         sync.Mutex
+	cached     int
+	cachedData []byte
+	payload    []byte
+	offset     int64
+}
+
+func NewSeekableReader(r io.ReaderAt, size int64, opts Options) (*SeekableReader, error) {
+	br := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	if magic, err := br.Peek(len(streamMagic)); err != nil || string(magic) != streamMagic {
+		return nil, ErrNoSeekIndex
+	}
+	h, err := readStreamHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if h.flags&flagSeekIndex == 0 {
+		return nil, ErrNoSeekIndex
+	}
+	bed := &BlockEncoderDecoder{opts: opts}
+	bc, err := bed.decodingCodec(h)
+	if err != nil {
+		return nil, err
+	}
+	index, err := readSeekIndex(r, size, h.flags)
+	if err != nil {
+		return nil, err
+	}
+	return &SeekableReader{r: r, bc: bc, flags: h.flags, index: index, cached: -1}, nil
+}
+
+// Size returns size of decompressed data.
+func (sr *SeekableReader) Size() int64 {
+	return int64(sr.index.entries[len(sr.index.entries)-1].rawOffset)
+}
+
+func (sr *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return sr.readAt(p, off)
+}
+
+func (sr *SeekableReader) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	entries := sr.index.entries
+	n := 0
+	for n < len(p) && off < sr.Size() {
+		// the last block starting at or before offset
+		i := sort.Search(len(entries), func(i int) bool {
+			return int64(entries[i].rawOffset) > off
+		}) - 1
+		block, err := sr.block(i)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off-int64(entries[i].rawOffset):])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// block returns i-th decompressed block.
+func (sr *SeekableReader) block(i int) ([]byte, error) {
+	if sr.cached == i {
+		return sr.cachedData, nil
+	}
+	start := int64(sr.index.entries[i].blockOffset)
+	end := int64(sr.index.entries[i+1].blockOffset)
+	br := bufio.NewReader(io.NewSectionReader(sr.r, start, end-start))
+	bh, err := readBlockHeader(br, sr.flags)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(bh.rawSize) != sr.index.entries[i+1].rawOffset-sr.index.entries[i].rawOffset {
+		return nil, fmt.Errorf("%w: block size differs from seek index", ErrCorruptedStream)
+	}
+	if cap(sr.payload) < bh.payloadSize {
+		sr.payload = make([]byte, bh.payloadSize)
+	}
+	sr.payload = sr.payload[:bh.payloadSize]
+	if _, err := io.ReadFull(br, sr.payload); err != nil {
+		return nil, err
+	}
+	if err := bh.verify(sr.flags, sr.payload); err != nil {
+		return nil, err
+	}
+	payload := sr.payload
+	if sr.bc.aead != nil {
+		bh.rawOffset = sr.index.entries[i].rawOffset
+		if payload, err = sr.bc.open(bh, sr.flags, payload); err != nil {
+			return nil, err
+		}
+	}
+	sr.cached = -1
+	if sr.cachedData, err = sr.bc.decompressBlock(sr.cachedData[:0], bh.method, payload, bh.rawSize); err != nil {
+		return nil, err
+	}
+	sr.bc.revertFilters(sr.cachedData)
+	sr.cached = i
+	return sr.cachedData, nil
+}
+
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if sr.offset >= sr.Size() {
+		return 0, io.EOF
+	}
+	n, err := sr.readAt(p, sr.offset)
+	sr.offset += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.offset
+	case io.SeekEnd:
+		offset += sr.Size()
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	sr.offset = offset
+	return offset, nil
+}
+
+var (
+	_ io.ReaderAt   = &SeekableReader{}
+	_ io.ReadSeeker = &SeekableReader{}
+)
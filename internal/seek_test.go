@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func seekTestStream(t *testing.T, input []byte, opts Options) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	if err := NewBlockEncoderDecoder(opts).Encode(bytes.NewReader(input), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	return encoded.Bytes()
+}
+
+func TestSeekableReaderReadAt(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := []byte(strings.Repeat("record with some payload\n", 40000))
+	for i := 0; i < len(input); i += 97 {
+		input[i] = byte(rng.Intn(256))
+	}
+	opts := Options{Level: MinLevel, SeekIndex: true, Filters: []Filter{{Kind: FilterDelta}}}
+	encoded := seekTestStream(t, input, opts)
+
+	// sequential decoding skips the index
+	var decoded bytes.Buffer
+	if err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(encoded), &decoded); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), input) {
+		t.Fatalf("Decoded data differs from input")
+	}
+
+	sr, err := NewSeekableReader(bytes.NewReader(encoded), int64(len(encoded)), Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if sr.Size() != int64(len(input)) {
+		t.Fatalf("Expected size %d, got %d", len(input), sr.Size())
+	}
+	blockSize := opts.levelParams().blockSize
+	for _, tt := range []struct {
+		name   string
+		off, n int
+	}{
+		{name: "Start", off: 0, n: 100},
+		{name: "Middle", off: len(input) / 2, n: 1000},
+		{name: "AcrossBlocks", off: blockSize - 10, n: 2*blockSize + 20},
+		{name: "End", off: len(input) - 5, n: 5},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := make([]byte, tt.n)
+			n, err := sr.ReadAt(p, int64(tt.off))
+			if err != nil || n != tt.n {
+				t.Fatalf("Expected %d bytes, got %d, %v", tt.n, n, err)
+			}
+			if !bytes.Equal(p, input[tt.off:tt.off+tt.n]) {
+				t.Fatalf("Read data differs from input")
+			}
+		})
+	}
+
+	p := make([]byte, 10)
+	if n, err := sr.ReadAt(p, int64(len(input)-4)); n != 4 || !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected 4 bytes and EOF, got %d, %v", n, err)
+	}
+}
+
+func TestSeekableReaderSeek(t *testing.T) {
+	input := make([]byte, 200000)
+	rand.New(rand.NewSource(2)).Read(input)
+	encoded := seekTestStream(t, input, Options{Level: MinLevel, SeekIndex: true})
+	sr, err := NewSeekableReader(bytes.NewReader(encoded), int64(len(encoded)), Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if _, err := sr.Seek(-50000, io.SeekEnd); err != nil {
+		t.Fatalf("Unexpected seek error: %s", err)
+	}
+	tail, err := io.ReadAll(sr)
+	if err != nil {
+		t.Fatalf("Unexpected read error: %s", err)
+	}
+	if !bytes.Equal(tail, input[len(input)-50000:]) {
+		t.Fatalf("Read data differs from input")
+	}
+}
+
+func TestSeekableReaderNoIndex(t *testing.T) {
+	encoded := seekTestStream(t, []byte("no index here"), Options{})
+	if _, err := NewSeekableReader(bytes.NewReader(encoded), int64(len(encoded)), Options{}); !errors.Is(err, ErrNoSeekIndex) {
+		t.Fatalf("Expected %v, got %v", ErrNoSeekIndex, err)
+	}
+}
+
+func TestSeekableReaderConcatenated(t *testing.T) {
+	encoded := seekTestStream(t, []byte("first"), Options{SeekIndex: true})
+	encoded = append(encoded, seekTestStream(t, []byte("second"), Options{SeekIndex: true})...)
+	if _, err := NewSeekableReader(bytes.NewReader(encoded), int64(len(encoded)), Options{}); !errors.Is(err, ErrSeekIndexMember) {
+		t.Fatalf("Expected %v, got %v", ErrSeekIndexMember, err)
+	}
+}
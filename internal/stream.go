@@ -0,0 +1,444 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Stream starts with magic, format version and flags followed by optional
+// header fields. Then come blocks, each with method byte, raw and payload
+// sizes and payload, terminated by blockEnd method. With flagBlockSync every
+// block and end marker start with syncMarker and store raw offset of block
+// after method and checksum of block before payload, so that damaged stream
+// can be recovered.
+const (
+	streamMagic   = "gcmp"
+	streamVersion = 1
+	syncMarker    = "\xf1gcb"
+
+	flagDictionary = 1 << 0
+	flagFilters    = 1 << 1
+	flagSeekIndex  = 1 << 2
+	flagBlockSync  = 1 << 3
+	flagEncrypted  = 1 << 4
+	flagMetadata   = 1 << 5
+
+	streamHeaderSize = len(streamMagic) + 2
+	// maxBlockHeaderSize bounds size of method, offset, sizes and checksum.
+	maxBlockHeaderSize = len(syncMarker) + 1 + binary.MaxVarintLen64 + 2*3 + 4
+	maxEndMarkerSize   = maxBlockHeaderSize
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("unsupported stream version")
+	ErrChecksumMismatch   = errors.New("block checksum mismatch")
+)
+
+// byteReader is satisfied by both bufio.Reader and bytes.Reader.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+type streamHeader struct {
+	flags      byte
+	dictID     uint32
+	filters    []Filter
+	encryption *encryptionHeader
+	metadata   *FileMetadata
+}
+
+func newStreamHeader(opts *Options) *streamHeader {
+	h := &streamHeader{filters: opts.Filters, metadata: opts.Metadata}
+	if opts.Dictionary != nil {
+		h.flags |= flagDictionary
+		h.dictID = dictionaryID(opts.Dictionary)
+	}
+	if len(opts.Filters) > 0 {
+		h.flags |= flagFilters
+	}
+	if opts.SeekIndex {
+		h.flags |= flagSeekIndex
+	}
+	if opts.Sync {
+		h.flags |= flagBlockSync
+	}
+	if opts.Passphrase != nil || opts.Key != nil {
+		h.flags |= flagEncrypted
+	}
+	if opts.Metadata != nil {
+		h.flags |= flagMetadata
+	}
+	return h
+}
+
+func (h *streamHeader) appendTo(dst []byte) []byte {
+	dst = append(append(dst, streamMagic...), streamVersion, h.flags)
+	if h.flags&flagDictionary != 0 {
+		dst = binary.LittleEndian.AppendUint32(dst, h.dictID)
+	}
+	if h.flags&flagFilters != 0 {
+		dst = appendFilters(dst, h.filters)
+	}
+	if h.flags&flagEncrypted != 0 {
+		dst = h.encryption.appendTo(dst)
+	}
+	if h.flags&flagMetadata != 0 {
+		dst = appendMetadata(dst, h.metadata)
+	}
+	return dst
+}
+
+func readStreamHeader(br byteReader) (*streamHeader, error) {
+	var hdr [streamHeaderSize]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, err
+	}
+	if version := hdr[len(streamMagic)]; version != streamVersion {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedVersion, version)
+	}
+	h := &streamHeader{flags: hdr[len(streamMagic)+1]}
+	if h.flags&flagDictionary != 0 {
+		if err := binary.Read(br, binary.LittleEndian, &h.dictID); err != nil {
+			return nil, err
+		}
+	}
+	if h.flags&flagFilters != 0 {
+		var err error
+		if h.filters, err = readFilters(br); err != nil {
+			return nil, err
+		}
+	}
+	if h.flags&flagEncrypted != 0 {
+		var err error
+		if h.encryption, err = readEncryptionHeader(br); err != nil {
+			return nil, err
+		}
+	}
+	if h.flags&flagMetadata != 0 {
+		var err error
+		if h.metadata, err = readMetadata(br); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+type BlockEncoderDecoder struct {
+	opts Options
+}
+
+// NewBlockEncoderDecoder returns codec that splits input into blocks and
+// compresses each of them with method chosen by options. Method and filters
+// are stored in the stream, so decoding needs only table and dictionary
+// options. Streams produced by HuffmanEncoderDecoder are decoded as well.
+func NewBlockEncoderDecoder(opts Options) EncoderDecoder {
+	return &BlockEncoderDecoder{opts: opts}
+}
+
+func (bed *BlockEncoderDecoder) Encode(r io.ReadSeeker, w io.Writer) error {
+	if len(bed.opts.Filters) > maxFilters {
+		return fmt.Errorf("too many filters: %d", len(bed.opts.Filters))
+	}
+	for _, f := range bed.opts.Filters {
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	if bed.opts.Table != nil && bed.opts.Entropy == EntropyTANS {
+		return ErrTableWithTANS
+	}
+	cw := &countingWriter{w: w}
+	buf := getBlockBuffers()
+	defer buf.release()
+	bw := buf.writer(cw)
+	h := newStreamHeader(&bed.opts)
+	if h.flags&flagEncrypted != 0 {
+		var err error
+		if h.encryption, err = newEncryptionHeader(&bed.opts); err != nil {
+			return err
+		}
+	}
+	bc := newBlockCodec(&bed.opts)
+	bc.huffman = &buf.huffman
+	if err := bc.setEncryption(h, &bed.opts); err != nil {
+		return err
+	}
+	if _, err := bw.Write(bc.header); err != nil {
+		return err
+	}
+
+	block := buf.blockOf(bc.params.blockSize)
+	payload := buf.payload[:0]
+	defer func() { buf.payload = payload }()
+	var index seekIndex
+	var rawOffset uint64
+	pr := bed.opts.Progress.reader(r, StageEncoding)
+	for {
+		n, readErr := io.ReadFull(pr, block)
+		if n > 0 {
+			index.add(rawOffset, uint64(cw.n)+uint64(bw.Buffered()))
+			bc.applyFilters(block[:n])
+			var method byte
+			var err error
+			payload, method, err = bc.compressBlock(payload[:0], block[:n], bed.opts.Strategy)
+			if err != nil {
+				return err
+			}
+			bh := &blockHeader{method: method, rawOffset: rawOffset, rawSize: n, payloadSize: len(payload)}
+			if bc.aead != nil {
+				payload = bc.seal(bh, h.flags, payload)
+			}
+			if err := bh.writeTo(bw, h.flags, payload); err != nil {
+				return err
+			}
+			rawOffset += uint64(n)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		} else if readErr != nil {
+			return readErr
+		}
+	}
+	index.add(rawOffset, uint64(cw.n)+uint64(bw.Buffered()))
+	end := &blockHeader{method: blockEnd, rawOffset: rawOffset}
+	var tag []byte
+	if bc.aead != nil {
+		tag = bc.seal(end, h.flags, nil)
+	}
+	if err := end.writeTo(bw, h.flags, tag); err != nil {
+		return err
+	}
+	if bed.opts.SeekIndex {
+		if err := index.writeTo(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// blockHeader describes block or end marker of stream.
+type blockHeader struct {
+	method      byte
+	rawOffset   uint64
+	rawSize     int
+	payloadSize int
+	checksum    uint32
+}
+
+// appendFields appends header fields covered by checksum.
+func (bh *blockHeader) appendFields(dst []byte, flags byte) []byte {
+	dst = append(dst, bh.method)
+	if flags&flagBlockSync == 0 {
+		if bh.method == blockEnd {
+			return dst
+		}
+	} else {
+		dst = binary.AppendUvarint(dst, bh.rawOffset)
+	}
+	dst = binary.AppendUvarint(dst, uint64(bh.rawSize))
+	return binary.AppendUvarint(dst, uint64(bh.payloadSize))
+}
+
+func (bh *blockHeader) blockChecksum(flags byte, payload []byte) uint32 {
+	crc := crc32.ChecksumIEEE(bh.appendFields(nil, flags))
+	return crc32.Update(crc, crc32.IEEETable, payload)
+}
+
+// size returns size of encoded header.
+func (bh *blockHeader) size(flags byte) int {
+	n := len(bh.appendFields(nil, flags))
+	if flags&flagBlockSync != 0 {
+		n += len(syncMarker) + 4
+	}
+	return n
+}
+
+func (bh *blockHeader) writeTo(w *bufio.Writer, flags byte, payload []byte) error {
+	var hdr []byte
+	if flags&flagBlockSync != 0 {
+		hdr = append(hdr, syncMarker...)
+	}
+	hdr = bh.appendFields(hdr, flags)
+	if flags&flagBlockSync != 0 {
+		hdr = binary.LittleEndian.AppendUint32(hdr, bh.blockChecksum(flags, payload))
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readBlockHeader(br byteReader, flags byte) (*blockHeader, error) {
+	if flags&flagBlockSync != 0 {
+		var marker [len(syncMarker)]byte
+		if _, err := io.ReadFull(br, marker[:]); err != nil {
+			return nil, err
+		}
+		if string(marker[:]) != syncMarker {
+			return nil, fmt.Errorf("%w: missing block sync marker", ErrCorruptedStream)
+		}
+	}
+	method, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	bh := &blockHeader{method: method}
+	if flags&flagBlockSync == 0 {
+		if method == blockEnd {
+			return bh, nil
+		}
+	} else if bh.rawOffset, err = binary.ReadUvarint(br); err != nil {
+		return nil, err
+	}
+	rawSize, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	payloadSize, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if rawSize > maxBlockSize || payloadSize > maxStageSize {
+		return nil, fmt.Errorf("%w: block of %d bytes", ErrCorruptedStream, rawSize)
+	}
+	bh.rawSize, bh.payloadSize = int(rawSize), int(payloadSize)
+	if flags&flagBlockSync != 0 {
+		if err := binary.Read(br, binary.LittleEndian, &bh.checksum); err != nil {
+			return nil, err
+		}
+	}
+	return bh, nil
+}
+
+// verify checks checksum of block if stream has it.
+func (bh *blockHeader) verify(flags byte, payload []byte) error {
+	if flags&flagBlockSync == 0 || bh.blockChecksum(flags, payload) == bh.checksum {
+		return nil
+	}
+	return fmt.Errorf("%w at offset %d", ErrChecksumMismatch, bh.rawOffset)
+}
+
+// Decode decodes concatenated streams one after another unless
+// Options.SingleMember is set.
+func (bed *BlockEncoderDecoder) Decode(r io.Reader, w io.Writer) error {
+	r, w = bed.opts.limitDecoding(r, w)
+	buf := getBlockBuffers()
+	defer buf.release()
+	br := buf.reader(bed.opts.Progress.reader(r, StageDecoding))
+	if magic, err := br.Peek(len(streamMagic)); err != nil || string(magic) != streamMagic {
+		return NewHuffmanEncoderDecoderWithTable(bed.opts.Table).Decode(br, w)
+	}
+	bw := buf.writer(w)
+	for {
+		if err := bed.decodeMember(br, bw, buf); err != nil {
+			return err
+		}
+		if bed.opts.SingleMember {
+			break
+		}
+		if _, err := br.Peek(1); errors.Is(err, io.EOF) {
+			break
+		}
+		if magic, err := br.Peek(len(streamMagic)); err != nil || string(magic) != streamMagic {
+			return fmt.Errorf("%w: trailing data after stream", ErrCorruptedStream)
+		}
+	}
+	return bw.Flush()
+}
+
+func (bed *BlockEncoderDecoder) decodeMember(br *bufio.Reader, bw *bufio.Writer, buf *blockBuffers) error {
+	h, err := readStreamHeader(br)
+	if err != nil {
+		return err
+	}
+	bc, err := bed.decodingCodec(h)
+	if err != nil {
+		return err
+	}
+	bc.huffman = &buf.huffman
+
+	payload, block := buf.payload, buf.block
+	defer func() { buf.payload, buf.block = payload, block }()
+	var rawOffset uint64
+	for {
+		bh, err := readBlockHeader(br, h.flags)
+		if err != nil {
+			return err
+		}
+		if h.flags&flagBlockSync == 0 {
+			bh.rawOffset = rawOffset
+		} else if bh.rawOffset != rawOffset {
+			return fmt.Errorf("%w: block at offset %d, expected %d", ErrCorruptedStream, bh.rawOffset, rawOffset)
+		}
+		if bh.method == blockEnd {
+			if bc.aead != nil {
+				if err := bc.openEnd(br, bh, h.flags); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if cap(payload) < bh.payloadSize {
+			payload = make([]byte, bh.payloadSize)
+		}
+		payload = payload[:bh.payloadSize]
+		if _, err := io.ReadFull(br, payload); err != nil {
+			return err
+		}
+		if err := bh.verify(h.flags, payload); err != nil {
+			return err
+		}
+		decrypted := payload
+		if bc.aead != nil {
+			if decrypted, err = bc.open(bh, h.flags, payload); err != nil {
+				return err
+			}
+		}
+		if block, err = bc.decompressBlock(block[:0], bh.method, decrypted, bh.rawSize); err != nil {
+			return err
+		}
+		bc.revertFilters(block)
+		if _, err := bw.Write(block); err != nil {
+			return err
+		}
+		rawOffset += uint64(bh.rawSize)
+	}
+	if h.flags&flagSeekIndex != 0 {
+		return skipSeekIndex(br)
+	}
+	return nil
+}
+
+// decodingCodec returns codec configured by stream header.
+func (bed *BlockEncoderDecoder) decodingCodec(h *streamHeader) (*blockCodec, error) {
+	bc := newBlockCodec(&bed.opts)
+	bc.filters = h.filters
+	if h.flags&flagDictionary == 0 {
+		bc.dict = nil
+	} else if bed.opts.Dictionary == nil || dictionaryID(bed.opts.Dictionary) != h.dictID {
+		return nil, fmt.Errorf("%w %08x", ErrDictionaryMismatch, h.dictID)
+	}
+	if err := bc.setEncryption(h, &bed.opts); err != nil {
+		return nil, err
+	}
+	return bc, nil
+}
+
+var _ EncoderDecoder = &BlockEncoderDecoder{}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+type testInput struct {
+	name  string
+	input []byte
+	// runs input is made of long runs, which RLE takes below 1 bit per byte.
+	runs bool
+}
+
+// testInputs returns inputs of codec tests: edge cases, test corpus and
+// synthetic data.
+func testInputs() []testInput {
+	dora, _ := os.ReadFile("../test/dora.jpg")
+	vimbook, _ := os.ReadFile("../test/vimbook.pdf")
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	sparse := make([]byte, 1<<18)
+	for i := 0; i < len(sparse); i += 4099 {
+		sparse[i] = byte(i)
+	}
+	bitmap := make([]byte, 0, 1<<16)
+	for row := 0; row < 256; row++ {
+		bitmap = append(bitmap, bytes.Repeat([]byte{0xff}, row/2)...)
+		bitmap = append(bitmap, bytes.Repeat([]byte{0x00}, 256-row/2)...)
+	}
+	longRuns := bytes.Repeat(append(bytes.Repeat([]byte{7}, 2*rleMaxRun+1), bytes.Repeat([]byte{9}, 1000)...), 20)
+	// the least frequent byte 0 becomes escape and occurs in runs too
+	escapes := make([]byte, 0, 1<<16)
+	for b := 0; b < bytesCount; b++ {
+		escapes = append(escapes, bytes.Repeat([]byte{byte(b)}, (b%7+1)*50)...)
+	}
+	return []testInput{
+		{name: "Empty", input: []byte{}},
+		{name: "OneByte", input: []byte{42}},
+		{name: "Zeros", input: make([]byte, 300000)},
+		{name: "Text", input: []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 3000))},
+		{name: "Random", input: random},
+		{name: "DoraJPG", input: dora},
+		{name: "VimBookPDF", input: vimbook[:1<<20]},
+		{name: "SparseBinary", input: sparse, runs: true},
+		{name: "Bitmap", input: bitmap, runs: true},
+		{name: "LongerThanMaxRun", input: longRuns, runs: true},
+		{name: "RunsOfEscape", input: escapes, runs: true},
+	}
+}
+
+func TestBlockEncodeDecode(t *testing.T) {
+	inputs := testInputs()
+	for _, tt := range []struct {
+		name string
+		opts Options
+	}{
+		{name: "Default", opts: Options{}},
+		{name: "HuffmanOnly", opts: Options{Strategy: StrategyHuffmanOnly}},
+		{name: "RLE", opts: Options{Strategy: StrategyRLE}},
+		{name: "LZ", opts: Options{Strategy: StrategyLZ, Level: 3}},
+		{name: "BWT", opts: Options{Strategy: StrategyBWT, Level: 1}},
+		{name: "TANS", opts: Options{Strategy: StrategyHuffmanOnly, Entropy: EntropyTANS}},
+		{name: "TANSBWT", opts: Options{Strategy: StrategyBWT, Entropy: EntropyTANS, Level: 1}},
+		{name: "Filters", opts: Options{Level: 2, Filters: []Filter{{Kind: FilterX86}, {Kind: FilterStride, Distance: 3}, {Kind: FilterDelta}}}},
+		{name: "AutoFastest", opts: Options{Level: MinLevel}},
+		{name: "AutoBest", opts: Options{Level: MaxLevel}},
+	} {
+		for _, in := range inputs {
+			t.Run(tt.name+"/"+in.name, func(t *testing.T) {
+				t.Parallel()
+				var encoded, decoded bytes.Buffer
+				bed := NewBlockEncoderDecoder(tt.opts)
+				if err := bed.Encode(bytes.NewReader(in.input), &encoded); err != nil {
+					t.Fatalf("Unexpected encoding error: %s", err)
+				}
+				// decoder needs no strategy and level
+				if err := NewBlockEncoderDecoder(Options{}).Decode(&encoded, &decoded); err != nil {
+					t.Fatalf("Unexpected decoding error: %s", err)
+				}
+				if !bytes.Equal(decoded.Bytes(), in.input) {
+					t.Fatalf("Initial and decoded data are different")
+				}
+			})
+		}
+	}
+}
+
+func TestBlockAutoStrategy(t *testing.T) {
+	input := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 3000))
+	sizes := map[Strategy]int{}
+	for _, s := range append([]Strategy{StrategyAuto}, autoCandidates...) {
+		var encoded bytes.Buffer
+		if err := NewBlockEncoderDecoder(Options{Strategy: s, Level: MaxLevel}).Encode(bytes.NewReader(input), &encoded); err != nil {
+			t.Fatalf("Unexpected encoding error: %s", err)
+		}
+		sizes[s] = encoded.Len()
+	}
+	for _, s := range autoCandidates {
+		if sizes[StrategyAuto] > sizes[s] {
+			t.Errorf("Auto strategy is worse than %s: %d and %d bytes", s, sizes[StrategyAuto], sizes[s])
+		}
+	}
+}
+
+func TestBlockDecodeLegacyHuffman(t *testing.T) {
+	input := []byte("abacaba")
+	var encoded, decoded bytes.Buffer
+	if err := NewHuffmanEncoderDecoder().Encode(bytes.NewReader(input), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	if err := NewBlockEncoderDecoder(Options{}).Decode(&encoded, &decoded); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), input) {
+		t.Errorf("Decoded data differs: expected %q, got %q", input, decoded.Bytes())
+	}
+}
+
+func TestBlockDictionaryAndTable(t *testing.T) {
+	table := trainJSONTable(t, jsonSamples)
+	for _, tt := range []struct {
+		name       string
+		input      []byte
+		encodeOpts Options
+		decodeOpts Options
+		err        error
+	}{
+		{
+			name:       "Dictionary",
+			input:      []byte(`{"timestamp":"2024-07-29T10:00:00Z","level":"info","url":"https://example.com/api/v1/users"}`),
+			encodeOpts: Options{Strategy: StrategyLZ, Dictionary: lzDictionary},
+			decodeOpts: Options{Dictionary: lzDictionary},
+		},
+		{
+			name:       "NoDictionary",
+			input:      []byte(`{"timestamp":"2024-07-29T10:00:00Z","level":"info","url":"https://example.com/api/v1/users"}`),
+			encodeOpts: Options{Strategy: StrategyLZ, Dictionary: lzDictionary},
+			err:        ErrDictionaryMismatch,
+		},
+		{
+			name:       "Table",
+			input:      []byte(jsonSamples[0] + jsonSamples[1]),
+			encodeOpts: Options{Strategy: StrategyHuffmanOnly, Table: table},
+			decodeOpts: Options{Table: table},
+		},
+		{
+			name:       "NoTable",
+			input:      []byte(jsonSamples[0] + jsonSamples[1]),
+			encodeOpts: Options{Strategy: StrategyHuffmanOnly, Table: table},
+			err:        ErrTableMismatch,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			if err := NewBlockEncoderDecoder(tt.encodeOpts).Encode(bytes.NewReader(tt.input), &encoded); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if encoded.Len() >= len(tt.input) {
+				t.Errorf("Input is not compressed: %d bytes of %d", encoded.Len(), len(tt.input))
+			}
+			err := NewBlockEncoderDecoder(tt.decodeOpts).Decode(&encoded, &decoded)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got %v", tt.err, err)
+			}
+			if err == nil && !bytes.Equal(decoded.Bytes(), tt.input) {
+				t.Errorf("Decoded data differs: expected %q, got %q", tt.input, decoded.Bytes())
+			}
+		})
+	}
+}
+
+func TestBlockDecodeUnsupportedVersion(t *testing.T) {
+	stream := []byte(streamMagic + "\x7f\x00\x00")
+	var decoded bytes.Buffer
+	err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(stream), &decoded)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestParseEntropy(t *testing.T) {
+	for _, e := range []Entropy{EntropyHuffman, EntropyTANS} {
+		if parsed, err := ParseEntropy(e.String()); err != nil || parsed != e {
+			t.Errorf("Entropy %s is parsed as %s with error %v", e, parsed, err)
+		}
+	}
+	if _, err := ParseEntropy("arithmetic"); err == nil {
+		t.Errorf("Unknown entropy coder is parsed without error")
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range append([]Strategy{StrategyAuto}, autoCandidates...) {
+		if parsed, err := ParseStrategy(s.String()); err != nil || parsed != s {
+			t.Errorf("Strategy %s is parsed as %s with error %v", s, parsed, err)
+		}
+	}
+	if _, err := ParseStrategy("zstd"); err == nil {
+		t.Errorf("Unknown strategy is parsed without error")
+	}
+}
+
+func TestBlockIncompressible(t *testing.T) {
+	random := make([]byte, 3*maxBlockSize+5)
+	rand.New(rand.NewSource(2)).Read(random)
+	for _, s := range append([]Strategy{StrategyAuto}, autoCandidates...) {
+		for _, size := range []int{0, 1, 1000, len(random)} {
+			t.Run(fmt.Sprintf("%s/%d", s, size), func(t *testing.T) {
+				t.Parallel()
+				opts := Options{Strategy: s, Level: MaxLevel}
+				var encoded, decoded bytes.Buffer
+				bed := NewBlockEncoderDecoder(opts)
+				if err := bed.Encode(bytes.NewReader(random[:size]), &encoded); err != nil {
+					t.Fatalf("Unexpected encoding error: %s", err)
+				}
+				blocks := (size + opts.levelParams().blockSize - 1) / opts.levelParams().blockSize
+				bound := size + streamHeaderSize + blocks*maxBlockHeaderSize + maxEndMarkerSize
+				if encoded.Len() > bound {
+					t.Errorf("Encoded data is too large: %d bytes, expected at most %d", encoded.Len(), bound)
+				}
+				if err := bed.Decode(&encoded, &decoded); err != nil {
+					t.Fatalf("Unexpected decoding error: %s", err)
+				}
+				if !bytes.Equal(decoded.Bytes(), random[:size]) {
+					t.Fatalf("Initial and decoded data are different")
+				}
+			})
+		}
+	}
+}
+
+func TestBlockConcatenatedStreams(t *testing.T) {
+	parts := [][]byte{
+		[]byte(strings.Repeat("first member ", 1000)),
+		{},
+		[]byte(strings.Repeat("second member with filters ", 1000)),
+	}
+	partOpts := []Options{
+		{},
+		{Strategy: StrategyRLE},
+		{Filters: []Filter{{Kind: FilterDelta}}, SeekIndex: true},
+	}
+	var encoded bytes.Buffer
+	for i, part := range parts {
+		if err := NewBlockEncoderDecoder(partOpts[i]).Encode(bytes.NewReader(part), &encoded); err != nil {
+			t.Fatalf("Unexpected encoding error: %s", err)
+		}
+	}
+
+	var decoded bytes.Buffer
+	if err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(encoded.Bytes()), &decoded); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), bytes.Join(parts, nil)) {
+		t.Fatalf("Decoded data differs from concatenated input")
+	}
+
+	decoded.Reset()
+	if err := NewBlockEncoderDecoder(Options{SingleMember: true}).Decode(bytes.NewReader(encoded.Bytes()), &decoded); err != nil {
+		t.Fatalf("Unexpected decoding error: %s", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), parts[0]) {
+		t.Fatalf("Decoded data differs from first member")
+	}
+
+	encoded.WriteString("garbage")
+	err := NewBlockEncoderDecoder(Options{}).Decode(bytes.NewReader(encoded.Bytes()), io.Discard)
+	if !errors.Is(err, ErrCorruptedStream) {
+		t.Fatalf("Expected %v, got %v", ErrCorruptedStream, err)
+	}
+}
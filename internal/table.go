@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+const huffmanTableMagic = "GHT\x01"
+
+var (
+	ErrNotHuffmanTable = errors.New("not a huffman table file")
+	ErrTableWithTANS   = errors.New("shared huffman table can not be used with tans entropy coder")
+)
+
+// HuffmanTable is huffman tree trained on sample corpus and shared between
+// encoder and decoder, so that small inputs do not carry their own tree.
+type HuffmanTable struct {
+	tree *huffmanTree
+	id   uint32
+}
+
+// smoothedFrequencies makes every byte encodable with trained table, even
+// ones that never occurred in samples.
+type smoothedFrequencies struct {
+	frequencyCounter
+}
+
+func (sf smoothedFrequencies) frequencyOf(b byte) uint64 {
+	return sf.frequencyCounter.frequencyOf(b) + 1
+}
+
+func (sf smoothedFrequencies) total() uint64 {
+	return sf.frequencyCounter.total() + bytesCount
+}
+
+var _ frequencyCounter = smoothedFrequencies{}
+
+func TrainHuffmanTable(samples ...io.Reader) (*HuffmanTable, error) {
+	fa, err := newFrequencyArray(io.MultiReader(samples...))
+	if err != nil {
+		return nil, err
+	}
+	return newHuffmanTable(newHuffmanTree(newForest(smoothedFrequencies{fa})))
+}
+
+func newHuffmanTable(ht *huffmanTree) (*HuffmanTable, error) {
+	var buf bytes.Buffer
+	if err := ht.writeTo(&buf); err != nil {
+		return nil, err
+	}
+	return &HuffmanTable{tree: ht, id: crc32.ChecksumIEEE(buf.Bytes())}, nil
+}
+
+func LoadHuffmanTable(r io.Reader) (*HuffmanTable, error) {
+	magic := make([]byte, len(huffmanTableMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != huffmanTableMagic {
+		return nil, ErrNotHuffmanTable
+	}
+	ht, err := readNewHuffmanTree(r)
+	if err != nil {
+		return nil, err
+	}
+	for b := 0; b < bytesCount; b++ {
+		if len(ht.charEncoding(byte(b))) == 0 {
+			return nil, ErrCorruptedTree
+		}
+	}
+	return newHuffmanTable(ht)
+}
+
+func (t *HuffmanTable) Save(w io.Writer) error {
+	if _, err := io.WriteString(w, huffmanTableMagic); err != nil {
+		return err
+	}
+	return t.tree.writeTo(w)
+}
+
+// ID identifies table in streams encoded with it.
+func (t *HuffmanTable) ID() uint32 {
+	return t.id
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var jsonSamples = []string{
+	`{"id":1,"user":"alice","event":"login","ok":true}`,
+	`{"id":2,"user":"bob","event":"logout","ok":true}`,
+	`{"id":3,"user":"carol","event":"login","ok":false}`,
+}
+
+func trainJSONTable(t *testing.T, samples []string) *HuffmanTable {
+	t.Helper()
+	rs := make([]io.Reader, len(samples))
+	for i, s := range samples {
+		rs[i] = strings.NewReader(s)
+	}
+	table, err := TrainHuffmanTable(rs...)
+	if err != nil {
+		t.Fatalf("Unexpected error during table training: %s", err)
+	}
+	return table
+}
+
+func TestHuffmanTableSaveLoad(t *testing.T) {
+	table := trainJSONTable(t, jsonSamples)
+	var buf bytes.Buffer
+	if err := table.Save(&buf); err != nil {
+		t.Fatalf("Unexpected error during table saving: %s", err)
+	}
+	loaded, err := LoadHuffmanTable(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error during table loading: %s", err)
+	}
+	if loaded.ID() != table.ID() {
+		t.Errorf("Loaded table ID differs: expected %08x, got %08x", table.ID(), loaded.ID())
+	}
+	for b := 0; b < bytesCount; b++ {
+		if len(loaded.tree.charEncoding(byte(b))) == 0 {
+			t.Errorf("Byte %d has no encoding in trained table", b)
+		}
+	}
+	if _, err := LoadHuffmanTable(strings.NewReader("not a table")); !errors.Is(err, ErrNotHuffmanTable) {
+		t.Errorf("Expected ErrNotHuffmanTable, got %v", err)
+	}
+}
+
+func TestHuffmanTableEncodeDecode(t *testing.T) {
+	table := trainJSONTable(t, jsonSamples)
+	for _, tt := range []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "SimilarMessage",
+			input: `{"id":4,"user":"dave","event":"login","ok":true}`,
+		},
+		{
+			name:  "UnseenBytes",
+			input: `{"id":5,"user":"` + "\u0000ÿZQX" + `","event":"login","ok":true}`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var withTable, withTree, decoded bytes.Buffer
+			hed := NewHuffmanEncoderDecoderWithTable(table)
+			if err := hed.Encode(strings.NewReader(tt.input), &withTable); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if err := NewHuffmanEncoderDecoder().Encode(strings.NewReader(tt.input), &withTree); err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			if withTable.Len() >= withTree.Len() {
+				t.Errorf("Table encoding is not smaller: %d with table, %d with tree",
+					withTable.Len(), withTree.Len())
+			}
+			if err := hed.Decode(&withTable, &decoded); err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if decoded.String() != tt.input {
+				t.Errorf("Decoded data differs: expected %q, got %q", tt.input, decoded.String())
+			}
+		})
+	}
+}
+
+func TestHuffmanTableWithTANS(t *testing.T) {
+	opts := Options{Entropy: EntropyTANS, Table: trainJSONTable(t, jsonSamples)}
+	err := NewBlockEncoderDecoder(opts).Encode(strings.NewReader(jsonSamples[0]), &bytes.Buffer{})
+	if !errors.Is(err, ErrTableWithTANS) {
+		t.Fatalf("Expected %v, got %v", ErrTableWithTANS, err)
+	}
+}
+
+func TestHuffmanTableMismatch(t *testing.T) {
+	table := trainJSONTable(t, jsonSamples)
+	other := trainJSONTable(t, []string{"aaaa", "bbbb", "cccc"})
+	var encoded bytes.Buffer
+	if err := NewHuffmanEncoderDecoderWithTable(table).Encode(strings.NewReader(jsonSamples[0]), &encoded); err != nil {
+		t.Fatalf("Unexpected encoding error: %s", err)
+	}
+	for _, tt := range []struct {
+		name string
+		hed  EncoderDecoder
+	}{
+		{
+			name: "NoTable",
+			hed:  NewHuffmanEncoderDecoder(),
+		},
+		{
+			name: "OtherTable",
+			hed:  NewHuffmanEncoderDecoderWithTable(other),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var decoded bytes.Buffer
+			err := tt.hed.Decode(bytes.NewReader(encoded.Bytes()), &decoded)
+			if !errors.Is(err, ErrTableMismatch) {
+				t.Errorf("Expected ErrTableMismatch, got %v", err)
+			}
+		})
+	}
+}
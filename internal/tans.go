@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go-compressor/pkg/bits"
+	"io"
+	mathbits "math/bits"
+)
+
+// tANS stage starts with table log (zero for empty stage), # of symbols,
+// bitmap of present bytes and their normalized frequencies minus one.
+// Then go initial decoder state and bits read by decoder after every symbol.
+const (
+	tansMinTableLog = 5
+	tansMaxTableLog = 12
+	tansBitmapSize  = bytesCount / byteBits
+)
+
+var ErrTANSNormalization = errors.New("tans frequencies can not be normalized")
+
+type tansTable struct {
+	tableLog int
+	norm     [bytesCount]uint32
+
+	// decoder state is index of symbols, next state is base plus nbBits
+	// read bits
+	symbols []byte
+	nbBits  []uint8
+	bases   []uint32
+
+	// positions[s] are decoder states with symbol s in increasing order
+	positions [bytesCount][]uint32
+}
+
+func tansTableLog(fc frequencyCounter) int {
+	present := 0
+	for b := 0; b < bytesCount; b++ {
+		if fc.frequencyOf(byte(b)) > 0 {
+			present++
+		}
+	}
+	tableLog := mathbits.Len64(fc.total())
+	tableLog = max(tableLog, mathbits.Len(uint(present))+1, tansMinTableLog)
+	return min(tableLog, tansMaxTableLog)
+}
+
+// newTANSTable scales frequencies so that they sum up to table size and
+// every present byte keeps non-zero frequency.
+func newTANSTable(fc frequencyCounter) (*tansTable, error) {
+	t := &tansTable{tableLog: tansTableLog(fc)}
+	size := uint64(1) << t.tableLog
+	var sum uint64
+	for b := 0; b < bytesCount; b++ {
+		if f := fc.frequencyOf(byte(b)); f > 0 {
+			t.norm[b] = uint32(max(1, (f*size+fc.total()/2)/fc.total()))
+			sum += uint64(t.norm[b])
+		}
+	}
+	for ; sum > size; sum-- {
+		b, ok := t.largest(2)
+		if !ok {
+			return nil, ErrTANSNormalization
+		}
+		t.norm[b]--
+	}
+	for ; sum < size; sum++ {
+		b, ok := t.largest(1)
+		if !ok {
+			return nil, ErrTANSNormalization
+		}
+		t.norm[b]++
+	}
+	t.build()
+	return t, nil
+}
+
+// largest returns byte of the largest frequency, if it is at least atLeast.
+func (t *tansTable) largest(atLeast uint32) (int, bool) {
+	largest := 0
+	for b := range t.norm {
+		if t.norm[b] > t.norm[largest] {
+			largest = b
+		}
+	}
+	return largest, t.norm[largest] >= atLeast
+}
+
+func (t *tansTable) build() {
+	size := 1 << t.tableLog
+	t.symbols = make([]byte, size)
+	step := size>>1 + size>>3 + 3
+	pos := 0
+	for b := 0; b < bytesCount; b++ {
+		for i := uint32(0); i < t.norm[b]; i++ {
+			t.symbols[pos] = byte(b)
+			pos = (pos + step) & (size - 1)
+		}
+	}
+
+	t.nbBits = make([]uint8, size)
+	t.bases = make([]uint32, size)
+	next := t.norm
+	for state, s := range t.symbols {
+		k := next[s]
+		next[s]++
+		nb := t.tableLog - (mathbits.Len32(k) - 1)
+		t.nbBits[state] = uint8(nb)
+		t.bases[state] = k<<nb - uint32(size)
+		t.positions[s] = append(t.positions[s], uint32(state))
+	}
+}
+
+func (t *tansTable) writeTo(w *bytes.Buffer) {
+	w.WriteByte(byte(t.tableLog))
+	var bitmap [tansBitmapSize]byte
+	for b, n := range t.norm {
+		if n > 0 {
+			bitmap[b/byteBits] |= 1 << (b % byteBits)
+		}
+	}
+	w.Write(bitmap[:])
+	for _, n := range t.norm {
+		if n > 0 {
+			w.Write(binary.AppendUvarint(nil, uint64(n-1)))
+		}
+	}
+}
+
+func readTANSTable(r *bytes.Reader, tableLog int) (*tansTable, error) {
+	if tableLog < tansMinTableLog || tableLog > tansMaxTableLog {
+		return nil, fmt.Errorf("%w: tans table log %d", ErrCorruptedStream, tableLog)
+	}
+	t := &tansTable{tableLog: tableLog}
+	var bitmap [tansBitmapSize]byte
+	if _, err := io.ReadFull(r, bitmap[:]); err != nil {
+		return nil, err
+	}
+	var sum uint64
+	for b := range t.norm {
+		if bitmap[b/byteBits]&(1<<(b%byteBits)) == 0 {
+			continue
+		}
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 1<<tableLog {
+			return nil, fmt.Errorf("%w: tans frequency %d", ErrCorruptedStream, n)
+		}
+		t.norm[b] = uint32(n + 1)
+		sum += n + 1
+	}
+	if sum != 1<<tableLog {
+		return nil, fmt.Errorf("%w: tans frequencies sum up to %d", ErrCorruptedStream, sum)
+	}
+	t.build()
+	return t, nil
+}
+
+// tansCompress appends table and codes of src to dst. Symbols are encoded in
+// reverse order, so that decoder reads bits forward.
+func tansCompress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	if len(src) == 0 {
+		buf.WriteByte(0)
+		return buf.Bytes(), nil
+	}
+	fa, err := newFrequencyArray(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	t, err := newTANSTable(fa)
+	if err != nil {
+		return nil, err
+	}
+	t.writeTo(buf)
+	buf.Write(binary.AppendUvarint(nil, uint64(len(src))))
+
+	size := uint32(1) << t.tableLog
+	// chunk keeps bits in low 16 bits and their # in high ones
+	chunks := make([]uint32, len(src))
+	state := size
+	for i := len(src) - 1; i >= 0; i-- {
+		s := src[i]
+		nb := 0
+		for state>>nb >= 2*t.norm[s] {
+			nb++
+		}
+		chunks[i] = uint32(nb)<<16 | state&(1<<nb-1)
+		state = size + t.positions[s][state>>nb-t.norm[s]]
+	}
+
+	bitw := bits.NewBitWriter(buf)
+	if err := bitw.WriteUint(uint64(state-size), t.tableLog); err != nil {
+		return nil, err
+	}
+	for _, c := range chunks {
+		if err := bitw.WriteUint(uint64(c&0xffff), int(c>>16)); err != nil {
+			return nil, err
+		}
+	}
+	if err := bitw.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func tansDecompress(payload []byte) ([]byte, error) {
+	r := bytes.NewReader(payload)
+	tableLog, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tableLog == 0 {
+		return nil, nil
+	}
+	t, err := readTANSTable(r, int(tableLog))
+	if err != nil {
+		return nil, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxStageSize {
+		return nil, fmt.Errorf("%w: stage of %d bytes", ErrCorruptedStream, n)
+	}
+
+	bitr := bits.NewBitReader(r)
+	state, err := bitr.ReadUint(t.tableLog)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = t.symbols[state]
+		v, err := bitr.ReadUint(int(t.nbBits[state]))
+		if err != nil {
+			return nil, err
+		}
+		state = uint64(t.bases[state]) + v
+	}
+	return out, nil
+}
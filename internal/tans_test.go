@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestTANSCompressDecompress(t *testing.T) {
+	random := make([]byte, 1<<16)
+	rand.New(rand.NewSource(4)).Read(random)
+	skewed := make([]byte, 1<<16)
+	for i := range skewed {
+		skewed[i] = byte(rand.New(rand.NewSource(int64(i))).ExpFloat64() * 4)
+	}
+	all := make([]byte, bytesCount)
+	for i := range all {
+		all[i] = byte(i)
+	}
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: []byte{}},
+		{name: "OneByte", input: []byte{'a'}},
+		{name: "SingleSymbol", input: bytes.Repeat([]byte{'z'}, 1000)},
+		{name: "AllBytesOnce", input: all},
+		{name: "Skewed", input: skewed},
+		{name: "Random", input: random},
+		{name: "Text", input: []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := tansCompress(nil, tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected encoding error: %s", err)
+			}
+			decoded, err := tansDecompress(encoded)
+			if err != nil {
+				t.Fatalf("Unexpected decoding error: %s", err)
+			}
+			if !bytes.Equal(decoded, tt.input) {
+				t.Fatalf("Initial and decoded data are different")
+			}
+		})
+	}
+}
+
+func TestTANSNormalization(t *testing.T) {
+	for _, input := range []string{"a", "abacaba", strings.Repeat("a", 100000) + "bcdefgh"} {
+		fa, _ := newFrequencyArray(strings.NewReader(input))
+		table, err := newTANSTable(fa)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		var sum uint32
+		for b := 0; b < bytesCount; b++ {
+			if (fa.frequencyOf(byte(b)) > 0) != (table.norm[b] > 0) {
+				t.Errorf("Presence of byte %d is changed by normalization", b)
+			}
+			sum += table.norm[b]
+		}
+		if sum != 1<<table.tableLog {
+			t.Errorf("Normalized frequencies sum up to %d, expected %d", sum, 1<<table.tableLog)
+		}
+	}
+}
+
+func TestTANSLargest(t *testing.T) {
+	table := &tansTable{}
+	table.norm['a'], table.norm['b'] = 1, 3
+	if b, ok := table.largest(2); !ok || b != 'b' {
+		t.Errorf("Expected byte 'b', got %d", b)
+	}
+	table.norm['b'] = 1
+	if _, ok := table.largest(2); ok {
+		t.Errorf("Expected no byte of frequency 2")
+	}
+}
+
+func TestTANSTableIsCompact(t *testing.T) {
+	input := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100))
+	fa, _ := newFrequencyArray(bytes.NewReader(input))
+	var tansBuf, treeBuf bytes.Buffer
+	table, err := newTANSTable(fa)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	table.writeTo(&tansBuf)
+	if err := newHuffmanTree(newForest(fa)).writeTo(&treeBuf); err != nil {
+		t.Fatalf("Unexpected error during HT writing: %s", err)
+	}
+	if tansBuf.Len()*4 > treeBuf.Len() {
+		t.Errorf("tANS table is not compact: %d bytes, huffman tree takes %d", tansBuf.Len(), treeBuf.Len())
+	}
+}
+
+func TestTANSCorruptedTable(t *testing.T) {
+	encoded, _ := tansCompress(nil, []byte("abacaba"))
+	for _, tt := range []struct {
+		name   string
+		modify func([]byte)
+	}{
+		{name: "TableLog", modify: func(b []byte) { b[0] = tansMaxTableLog + 1 }},
+		{name: "FrequenciesSum", modify: func(b []byte) { b[1+tansBitmapSize]++ }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			corrupted := append([]byte(nil), encoded...)
+			tt.modify(corrupted)
+			if _, err := tansDecompress(corrupted); !errors.Is(err, ErrCorruptedStream) {
+				t.Errorf("Expected ErrCorruptedStream, got %v", err)
+			}
+		})
+	}
+}
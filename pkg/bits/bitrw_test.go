@@ -107,3 +107,72 @@ func TestBitWriteRead(t *testing.T) {
 		})
 	}
 }
+
+func TestUintWriteRead(t *testing.T) {
+	values := []struct {
+		v     uint64
+		width int
+	}{
+		{v: 0, width: 9},
+		{v: 256, width: 9},
+		{v: 511, width: 9},
+		{v: 1000, width: 10},
+		{v: 1, width: 1},
+		{v: 65535, width: 16},
+		{v: 5, width: 3},
+		{v: 1 << 63, width: 64},
+	}
+	f, _ := os.CreateTemp(t.TempDir(), "uints")
+	bw := NewBitWriter(f)
+	for _, val := range values {
+		if err := bw.WriteUint(val.v, val.width); err != nil {
+			t.Fatalf("Unexpected error during write: %s", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("Unexpected error during flushing: %s", err)
+	}
+	_, _ = f.Seek(0, 0)
+
+	br := NewBitReader(f)
+	for i, val := range values {
+		if v, err := br.ReadUint(val.width); err != nil {
+			t.Fatalf("Unexpected error during read: %s", err)
+		} else if v != val.v {
+			t.Errorf("%d-th read value differs from expected: want %d, got %d", i, val.v, v)
+		}
+	}
+}
+
+const benchmarkBytes = 1 << 16
+
+func BenchmarkBitWriterWriteBits(b *testing.B) {
+	code := []bool{true, false, true, true, false, false, true, false}
+	b.SetBytes(benchmarkBytes)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		bw := NewBitWriter(io.Discard)
+		for j := 0; j < benchmarkBytes; j++ {
+			if err := bw.WriteBits(code...); err != nil {
+				b.Fatalf("Unexpected error during write: %s", err)
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			b.Fatalf("Unexpected error during flushing: %s", err)
+		}
+	}
+}
+
+func BenchmarkBitReaderReadBit(b *testing.B) {
+	data := strings.Repeat("\xa5", benchmarkBytes)
+	b.SetBytes(benchmarkBytes)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(strings.NewReader(data))
+		for j := 0; j < benchmarkBytes*byteSize; j++ {
+			if _, err := br.ReadBit(); err != nil {
+				b.Fatalf("Unexpected error during read: %s", err)
+			}
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 type BitReader interface {
 	ReadBit() (bool, error)
+	// ReadUint reads width bits written by BitWriter.WriteUint.
+	ReadUint(width int) (uint64, error)
 }
 
 const (
@@ -44,4 +46,16 @@ func (br *BitReaderImpl) ReadBit() (bool, error) {
 	return bit, nil
 }
 
+func (br *BitReaderImpl) ReadUint(width int) (uint64, error) {
+	var v uint64
+	for i := 0; i < width; i++ {
+		if b, err := br.ReadBit(); err != nil {
+			return 0, err
+		} else if b {
+			v |= 1 << i
+		}
+	}
+	return v, nil
+}
+
 var _ BitReader = &BitReaderImpl{}
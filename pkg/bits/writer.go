@@ -6,6 +6,9 @@ import (
 
 type BitWriter interface {
 	WriteBits(...bool) error
+	// WriteUint writes width lower bits of v, starting from the least
+	// significant one.
+	WriteUint(v uint64, width int) error
 	Flush() error
 }
 
@@ -43,6 +46,15 @@ func (bw *BitWriterImpl) WriteBits(bs ...bool) error {
 	return nil
 }
 
+func (bw *BitWriterImpl) WriteUint(v uint64, width int) error {
+	for i := 0; i < width; i++ {
+		if err := bw.WriteBits(v&(1<<i) > 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (bw *BitWriterImpl) Flush() error {
 	return bw.writeByte()
 }